@@ -2,23 +2,143 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"github.com/botless/commands/pkg/acl"
+	"github.com/botless/commands/pkg/admin"
 	"github.com/botless/commands/pkg/commands"
+	_ "github.com/botless/commands/pkg/commands/calc"
+	_ "github.com/botless/commands/pkg/commands/core"
+	_ "github.com/botless/commands/pkg/commands/dice"
+	"github.com/botless/commands/pkg/commands/karma"
+	"github.com/botless/commands/pkg/commands/poll"
+	"github.com/botless/commands/pkg/commands/remind"
+	"github.com/botless/commands/pkg/dedupe"
+	"github.com/botless/commands/pkg/logging"
+	"github.com/botless/commands/pkg/metrics"
+	"github.com/botless/commands/pkg/ratelimit"
+	"github.com/botless/commands/pkg/store"
+	"github.com/botless/commands/pkg/tracing"
 	"github.com/cloudevents/sdk-go/pkg/cloudevents/client"
 	clienthttp "github.com/cloudevents/sdk-go/pkg/cloudevents/client/http"
 	"github.com/cloudevents/sdk-go/pkg/cloudevents/transport/http"
 	"github.com/kelseyhightower/envconfig"
 	"log"
+	nethttp "net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 type envConfig struct {
 	// Port is server port to be listened.
 	Port int `envconfig:"USER_PORT" default:"8080"`
 
-	// Target is the endpoint to receive cloudevents.
-	Target string `envconfig:"TARGET" required:"true"`
+	// Target is the endpoint to receive cloudevents. Required unless
+	// ReplyMode is "reply".
+	Target string `envconfig:"TARGET" default:""`
 
-	// StrictType is the type this function will only handle.
+	// SendMaxAttempts is the number of times sending a response is tried.
+	SendMaxAttempts int `envconfig:"SEND_MAX_ATTEMPTS" default:"3"`
+
+	// SendInitialBackoff is the delay before the first retry of a send.
+	SendInitialBackoff time.Duration `envconfig:"SEND_INITIAL_BACKOFF" default:"100ms"`
+
+	// SendMaxBackoff caps the delay between retries of a send.
+	SendMaxBackoff time.Duration `envconfig:"SEND_MAX_BACKOFF" default:"5s"`
+
+	// DeadLetterTarget is the endpoint to receive responses that could not be
+	// delivered.
+	DeadLetterTarget string `envconfig:"DEAD_LETTER_TARGET" default:""`
+
+	// Workers is the number of events handled concurrently.
+	Workers int `envconfig:"WORKERS" default:"16"`
+
+	// QueueSize is the number of events accepted while all workers are busy.
+	QueueSize int `envconfig:"QUEUE_SIZE" default:"100"`
+
+	// Synchronous handles each event before acknowledging it, so failed
+	// sends are retried by the sender.
+	Synchronous bool `envconfig:"SYNCHRONOUS" default:"false"`
+
+	// RateLimitAuthor, RateLimitChannel and RateLimitGlobal are the default
+	// limits, e.g. "5/1m", applied per command to each author, each channel
+	// and overall. Empty is unlimited.
+	RateLimitAuthor  string `envconfig:"RATE_LIMIT_AUTHOR" default:""`
+	RateLimitChannel string `envconfig:"RATE_LIMIT_CHANNEL" default:""`
+	RateLimitGlobal  string `envconfig:"RATE_LIMIT_GLOBAL" default:""`
+
+	// RateLimitReply tells users once per window that they are rate limited.
+	RateLimitReply bool `envconfig:"RATE_LIMIT_REPLY" default:"true"`
+
+	// DedupeTTL is how long event IDs are remembered to drop redeliveries.
+	// 0 disables dedupe.
+	DedupeTTL time.Duration `envconfig:"DEDUPE_TTL" default:"10m"`
+
+	// DedupeSize is the number of event IDs remembered in memory. Unused
+	// with StoreURL, which remembers them in the shared store.
+	DedupeSize int `envconfig:"DEDUPE_SIZE" default:"10000"`
+
+	// ACLFile is a YAML or JSON policy deciding who may run which command
+	// where. It is reloaded when changed.
+	ACLFile string `envconfig:"ACL_FILE" default:""`
+
+	// ACLReloadInterval is how often ACLFile is checked for changes.
+	ACLReloadInterval time.Duration `envconfig:"ACL_RELOAD_INTERVAL" default:"10s"`
+
+	// Pipelines enables chaining commands with |, e.g. "echo hi | caps".
+	Pipelines bool `envconfig:"PIPELINES" default:"false"`
+
+	// SuggestCommands replies to unknown commands with "did you mean".
+	SuggestCommands bool `envconfig:"SUGGEST_COMMANDS" default:"false"`
+
+	// StoreFile persists the state of stateful commands. When empty state is
+	// only kept in memory.
+	StoreFile string `envconfig:"STORE_FILE" default:""`
+
+	// StoreURL is a store served by cmd/store, shared with other replicas
+	// and services. Replaces StoreFile.
+	StoreURL string `envconfig:"STORE_URL" default:""`
+
+	// RemindTimezone is the time zone reminder times are read in.
+	RemindTimezone string `envconfig:"REMIND_TIMEZONE" default:"UTC"`
+	// RemindInterval is how often due reminders are looked for.
+	RemindInterval time.Duration `envconfig:"REMIND_INTERVAL" default:"10s"`
+
+	// KarmaCooldown is how often a user may change the karma of another.
+	KarmaCooldown time.Duration `envconfig:"KARMA_COOLDOWN" default:"1m"`
+
+	// DisabledCommands is a comma separated list of commands to ignore.
+	DisabledCommands string `envconfig:"DISABLED_COMMANDS" default:""`
+
+	// MetricsPort is the port metrics are served on at /metrics. 0 disables
+	// metrics.
+	MetricsPort int `envconfig:"METRICS_PORT" default:"9090"`
+
+	// TraceExporter selects where spans are exported: "stdout", "otlp" or
+	// "" to disable tracing.
+	TraceExporter string `envconfig:"TRACE_EXPORTER" default:""`
+
+	// OTLPEndpoint is the collector endpoint used by the "otlp" exporter.
+	OTLPEndpoint string `envconfig:"OTLP_ENDPOINT" default:"http://localhost:4318/v1/traces"`
+
+	// LogLevel is the minimum level logged: debug, info, warn or error.
+	LogLevel string `envconfig:"LOG_LEVEL" default:"info"`
+
+	// LogFormat is "text" or "json".
+	LogFormat string `envconfig:"LOG_FORMAT" default:"text"`
+
+	// ShutdownTimeout is how long in flight commands are given to finish
+	// once a termination signal is received.
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"20s"`
+
+	// ReplyMode selects how responses are delivered: "send" posts them to
+	// Target, "reply" returns them in the response to the incoming event.
+	ReplyMode string `envconfig:"REPLY_MODE" default:"send"`
+
+	// StrictType is a comma separated list of type patterns this function
+	// will only handle. Patterns prefixed with "!" are excluded.
 	StrictType string `envconfig:"STRICT_TYPE" default:""`
 }
 
@@ -33,29 +153,278 @@ func _main(args []string) int {
 		return 1
 	}
 
-	c, err := clienthttp.New(
-		http.WithTarget(env.Target),
+	level, err := logging.ParseLevel(env.LogLevel)
+	if err != nil {
+		log.Printf("[ERROR] Failed to parse LOG_LEVEL: %s", err)
+		return 1
+	}
+	if env.LogFormat != "text" && env.LogFormat != "json" {
+		log.Printf("[ERROR] Unknown LOG_FORMAT %q, expected \"text\" or \"json\"", env.LogFormat)
+		return 1
+	}
+	logger := logging.New(os.Stderr, level, env.LogFormat == "json")
+	logging.SetDefault(logger)
+	// Route the standard logger, used by the cloudevents sdk, through logger.
+	log.SetFlags(0)
+	log.SetOutput(logger.Writer(logging.Info))
+
+	filter, err := commands.ParseTypeFilter(env.StrictType)
+	if err != nil {
+		logger.Errorf("Failed to parse STRICT_TYPE: %s", err)
+		return 1
+	}
+
+	opts := []http.Option{
 		http.WithPort(env.Port),
 		http.WithBinaryEncoding(),
-		client.WithTimeNow(),
-		client.WithUUIDs(),
-	)
+	}
+	switch env.ReplyMode {
+	case "send":
+		if env.Target == "" {
+			logger.Errorf("TARGET is required when REPLY_MODE is %q", env.ReplyMode)
+			return 1
+		}
+	case "reply":
+	default:
+		logger.Errorf("Unknown REPLY_MODE %q, expected \"send\" or \"reply\"", env.ReplyMode)
+		return 1
+	}
+	if env.Target != "" {
+		opts = append(opts, http.WithTarget(env.Target))
+	}
+
+	t, err := http.New(opts...)
 	if err != nil {
-		log.Fatalf("Failed to create client: %s", err.Error())
+		logger.Errorf("Failed to create transport: %s", err)
+		return 1
+	}
+	// The transport serves cloudevents on "/" of this mux, leaving the other
+	// paths for the health and admin endpoints.
+	mux := nethttp.NewServeMux()
+	t.Handler = mux
+
+	c, err := client.New(t, client.WithTimeNow(), client.WithUUIDs())
+	if err != nil {
+		logger.Errorf("Failed to create client: %s", err)
+		return 1
+	}
+
+	sender := &commands.Sender{
+		Ce:             c,
+		MaxAttempts:    env.SendMaxAttempts,
+		InitialBackoff: env.SendInitialBackoff,
+		MaxBackoff:     env.SendMaxBackoff,
+	}
+	if env.DeadLetterTarget != "" {
+		sender.DeadLetter, err = clienthttp.New(
+			http.WithTarget(env.DeadLetterTarget),
+			http.WithBinaryEncoding(),
+			client.WithTimeNow(),
+			client.WithUUIDs(),
+		)
+		if err != nil {
+			logger.Errorf("Failed to create dead letter client: %s", err)
+			return 1
+		}
 	}
 
 	cmds := &commands.Commands{
-		Ce:         c,
-		StrictType: env.StrictType,
+		Ce:             c,
+		Sender:         sender,
+		Filter:         filter,
+		Workers:        env.Workers,
+		QueueSize:      env.QueueSize,
+		Synchronous:    env.Synchronous,
+		Logger:         logger,
+		RateLimitReply: env.RateLimitReply,
+		Pipelines:      env.Pipelines,
+		Suggest:        env.SuggestCommands,
+	}
+
+	for _, l := range []struct {
+		name  string
+		value string
+		limit *ratelimit.Limit
+	}{
+		{"RATE_LIMIT_AUTHOR", env.RateLimitAuthor, &cmds.RateLimits.PerAuthor},
+		{"RATE_LIMIT_CHANNEL", env.RateLimitChannel, &cmds.RateLimits.PerChannel},
+		{"RATE_LIMIT_GLOBAL", env.RateLimitGlobal, &cmds.RateLimits.Global},
+	} {
+		if *l.limit, err = ratelimit.ParseLimit(l.value); err != nil {
+			logger.Errorf("Failed to parse %s: %s", l.name, err)
+			return 1
+		}
+	}
+
+	switch {
+	case env.StoreURL != "" && env.StoreFile != "":
+		logger.Errorf("Only one of STORE_URL and STORE_FILE can be set")
+		return 1
+	case env.StoreURL != "":
+		cmds.Store = store.NewRemote(env.StoreURL)
+	case env.StoreFile != "":
+		fs, err := store.OpenFile(env.StoreFile)
+		if err != nil {
+			logger.Errorf("Failed to open STORE_FILE: %s", err)
+			return 1
+		}
+		cmds.Store = fs
+	default:
+		cmds.Store = store.NewMemory()
 	}
+	defer cmds.Store.Close()
 
-	ctx := context.Background()
-	if err := c.StartReceiver(ctx, cmds.Receive); err != nil {
-		log.Fatalf("Failed to start reveiver client: %s", err.Error())
+	if env.DedupeTTL > 0 {
+		// Replicas sharing a store drop the redeliveries handled by each other.
+		if env.StoreURL != "" {
+			cmds.Dedupe = dedupe.NewShared(cmds.Store)
+		} else {
+			cmds.Dedupe = dedupe.NewLRU(env.DedupeSize)
+		}
+		cmds.DedupeTTL = env.DedupeTTL
 	}
-	log.Printf("core commands listening on :%d", env.Port)
+
+	aliases := &commands.StoreAliases{Store: cmds.Store}
+	cmds.Aliases = aliases
+	commands.MustRegister(commands.AliasCommand(commands.DefaultRegistry, aliases))
+
+	commands.MustRegister(karma.Command(env.KarmaCooldown))
+	commands.MustRegister(poll.Command())
+	commands.MustRegister(poll.VoteCommand())
+
+	// Reminders are delivered to TARGET, so they are only offered with one,
+	// and only the services handling remind deliver them.
+	var scheduler *remind.Scheduler
+	if env.Target != "" {
+		loc, err := time.LoadLocation(env.RemindTimezone)
+		if err != nil {
+			logger.Errorf("Failed to load REMIND_TIMEZONE: %s", err)
+			return 1
+		}
+		commands.MustRegister(remind.Command(loc))
+	}
+	if env.Target != "" && filter.Match(commands.CommandType("remind")) {
+		scheduler = &remind.Scheduler{
+			Store:    cmds.Store,
+			Sender:   sender,
+			Interval: env.RemindInterval,
+			Logger:   logger,
+		}
+	}
+	// Without a TARGET expired polls stop taking votes, but their results
+	// are only shown when asked for. Only the services handling poll close
+	// them.
+	var closer *poll.Closer
+	if env.Target != "" && filter.Match(commands.CommandType("poll")) {
+		closer = &poll.Closer{
+			Store:  cmds.Store,
+			Sender: sender,
+			Logger: logger,
+		}
+	}
+
+	for _, name := range strings.Split(env.DisabledCommands, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if err := commands.DefaultRegistry.SetEnabled(name, false); err != nil {
+			logger.Errorf("Failed to disable command: %s", err)
+			return 1
+		}
+	}
+
+	registry := metrics.NewRegistry()
+	cmds.Metrics = commands.NewMetrics(registry)
+	if env.MetricsPort != 0 {
+		metricsMux := nethttp.NewServeMux()
+		metricsMux.Handle("/metrics", registry)
+		go func() {
+			logger.Infof("metrics listening on :%d", env.MetricsPort)
+			if err := nethttp.ListenAndServe(fmt.Sprintf(":%d", env.MetricsPort), metricsMux); err != nil {
+				logger.Errorf("Failed to serve metrics: %s", err)
+			}
+		}()
+	}
+
+	exporter, err := tracing.NewExporter(env.TraceExporter, env.OTLPEndpoint, "botless-commands", os.Stdout)
+	if err != nil {
+		logger.Errorf("Failed to create trace exporter: %s", err)
+		return 1
+	}
+	if exporter != nil {
+		cmds.Tracer = &tracing.Tracer{
+			Service:  "botless-commands",
+			Exporter: exporter,
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if env.ACLFile != "" {
+		policy, err := acl.Load(env.ACLFile)
+		if err != nil {
+			logger.Errorf("Failed to load ACL_FILE: %s", err)
+			return 1
+		}
+		cmds.ACL = policy
+		go policy.Watch(ctx, env.ACLReloadInterval)
+	}
+	if scheduler != nil {
+		go scheduler.Run(ctx)
+	}
+	if closer != nil {
+		go closer.Run(ctx)
+	}
+
+	var fn interface{} = cmds.Receive
+	if env.ReplyMode == "reply" {
+		fn = cmds.ReceiveAndReply
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		s := <-signals
+		logger.Infof("core commands received %s, shutting down", s)
+		cancel()
+	}()
+
+	adminServer := &admin.Server{
+		Registry: commands.DefaultRegistry,
+		Metrics:  cmds.Metrics,
+		Target:   env.Target,
+	}
+	adminServer.Register(mux)
+
+	if err := c.StartReceiver(ctx, fn); err != nil {
+		logger.Errorf("Failed to start receiver client: %s", err)
+		return 1
+	}
+	adminServer.SetReady(true)
+	logger.Infof("core commands listening on :%d", env.Port)
 	<-ctx.Done()
-	log.Printf("core commands done")
+	adminServer.SetReady(false)
+
+	// New events are rejected while draining so the sender retries them
+	// elsewhere, then the receiver is stopped.
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), env.ShutdownTimeout)
+	defer shutdownCancel()
+	code := 0
+	if err := cmds.Drain(shutdownCtx); err != nil {
+		logger.Errorf("Failed to drain commands: %s", err)
+		code = 1
+	}
+	if err := c.StopReceiver(shutdownCtx); err != nil {
+		logger.Errorf("Failed to stop receiver: %s", err)
+		code = 1
+	}
+	if otlp, ok := exporter.(*tracing.OTLPExporter); ok {
+		if err := otlp.Shutdown(shutdownCtx); err != nil {
+			logger.Errorf("Failed to flush spans: %s", err)
+		}
+	}
+	logger.Infof("core commands done")
 
-	return 0
+	return code
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/botless/commands/pkg/logging"
+	"github.com/botless/commands/pkg/store"
+	"github.com/kelseyhightower/envconfig"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+type envConfig struct {
+	// Port is server port to be listened.
+	Port int `envconfig:"USER_PORT" default:"8080"`
+
+	// StoreFile is where the served store is persisted.
+	StoreFile string `envconfig:"STORE_FILE" required:"true"`
+
+	// LogLevel is the minimum level logged: debug, info, warn or error.
+	LogLevel string `envconfig:"LOG_LEVEL" default:"info"`
+
+	// LogFormat is "text" or "json".
+	LogFormat string `envconfig:"LOG_FORMAT" default:"text"`
+
+	// ShutdownTimeout is how long in flight requests are given to finish
+	// once a termination signal is received.
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"20s"`
+}
+
+func main() {
+	os.Exit(_main(os.Args[1:]))
+}
+
+func _main(args []string) int {
+	var env envConfig
+	if err := envconfig.Process("", &env); err != nil {
+		log.Printf("[ERROR] Failed to process env var: %s", err)
+		return 1
+	}
+
+	level, err := logging.ParseLevel(env.LogLevel)
+	if err != nil {
+		log.Printf("[ERROR] Failed to parse LOG_LEVEL: %s", err)
+		return 1
+	}
+	if env.LogFormat != "text" && env.LogFormat != "json" {
+		log.Printf("[ERROR] Unknown LOG_FORMAT %q, expected \"text\" or \"json\"", env.LogFormat)
+		return 1
+	}
+	logger := logging.New(os.Stderr, level, env.LogFormat == "json")
+	logging.SetDefault(logger)
+
+	s, err := store.OpenFile(env.StoreFile)
+	if err != nil {
+		logger.Errorf("Failed to open STORE_FILE: %s", err)
+		return 1
+	}
+	defer s.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok\n"))
+	})
+	mux.Handle("/", store.Handler(s))
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", env.Port),
+		Handler: mux,
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	done := make(chan struct{})
+	go func() {
+		sig := <-signals
+		logger.Infof("store received %s, shutting down", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), env.ShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Errorf("Failed to shut down: %s", err)
+		}
+		close(done)
+	}()
+
+	logger.Infof("store listening on :%d", env.Port)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		logger.Errorf("Failed to serve: %s", err)
+		return 1
+	}
+	<-done
+	logger.Infof("store done")
+	return 0
+}
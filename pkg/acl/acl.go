@@ -0,0 +1,138 @@
+// Package acl decides which authors may run which commands in which
+// channels, based on a YAML or JSON policy.
+//
+// An example policy:
+//
+//	admins: ["U024BE7LH"]
+//	default: allow
+//	rules:
+//	- effect: deny
+//	  commands: ["flip"]
+//	  channels: ["C0G9QF9GW"]
+//	- effect: allow
+//	  authors: ["U0*"]
+//	  commands: ["*"]
+//
+// Rules are evaluated in order and the first matching rule decides. A rule
+// matches when each of its non-empty lists has an entry matching the
+// request; entries are path.Match patterns. When no rule matches, Default
+// decides, which is allow unless set to deny. Admins may run every command,
+// including admin only commands which nobody else may run.
+package acl
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"path"
+)
+
+type Effect string
+
+const (
+	Allow Effect = "allow"
+	Deny  Effect = "deny"
+)
+
+type Rule struct {
+	Effect   Effect   `yaml:"effect"`
+	Authors  []string `yaml:"authors,omitempty"`
+	Channels []string `yaml:"channels,omitempty"`
+	Commands []string `yaml:"commands,omitempty"`
+}
+
+type Policy struct {
+	Admins  []string `yaml:"admins,omitempty"`
+	Default Effect   `yaml:"default,omitempty"`
+	Rules   []Rule   `yaml:"rules,omitempty"`
+}
+
+// Parse parses a policy in YAML or JSON.
+func Parse(data []byte) (*Policy, error) {
+	p := &Policy{}
+	if err := yaml.UnmarshalStrict(data, p); err != nil {
+		return nil, err
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Validate checks effects and patterns are well formed.
+func (p *Policy) Validate() error {
+	if err := validEffect(p.Default, true); err != nil {
+		return fmt.Errorf("default: %s", err)
+	}
+	for i, r := range p.Rules {
+		if err := validEffect(r.Effect, false); err != nil {
+			return fmt.Errorf("rule %d: %s", i, err)
+		}
+		for _, list := range [][]string{r.Authors, r.Channels, r.Commands} {
+			for _, pattern := range list {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return fmt.Errorf("rule %d: invalid pattern %q: %s", i, pattern, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validEffect(e Effect, optional bool) error {
+	switch e {
+	case Allow, Deny:
+		return nil
+	case "":
+		if optional {
+			return nil
+		}
+		return fmt.Errorf("effect is required")
+	default:
+		return fmt.Errorf("unknown effect %q, expected %q or %q", e, Allow, Deny)
+	}
+}
+
+// IsAdmin reports whether author is listed as an admin.
+func (p *Policy) IsAdmin(author string) bool {
+	if p == nil || author == "" {
+		return false
+	}
+	for _, a := range p.Admins {
+		if a == author {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed reports whether author may run command in channel. A nil policy
+// allows every command that is not admin only.
+func (p *Policy) Allowed(author, channel, command string, adminOnly bool) bool {
+	if p.IsAdmin(author) {
+		return true
+	}
+	if adminOnly {
+		return false
+	}
+	if p == nil {
+		return true
+	}
+	for _, r := range p.Rules {
+		if matches(r.Authors, author) && matches(r.Channels, channel) && matches(r.Commands, command) {
+			return r.Effect == Allow
+		}
+	}
+	return p.Default != Deny
+}
+
+func matches(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, value); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package acl
+
+import (
+	"testing"
+)
+
+const policy = `
+admins: ["UADMIN"]
+default: deny
+rules:
+- effect: deny
+  commands: ["flip"]
+  channels: ["C0G9QF9GW"]
+- effect: allow
+  authors: ["U0*"]
+- effect: allow
+  channels: ["CPUBLIC", "CRAND*"]
+  commands: ["echo", "help"]
+`
+
+func TestAllowed(t *testing.T) {
+	p, err := Parse([]byte(policy))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	tests := []struct {
+		name      string
+		policy    *Policy
+		author    string
+		channel   string
+		command   string
+		adminOnly bool
+		want      bool
+	}{
+		{"first matching rule decides", p, "U01", "C0G9QF9GW", "flip", false, false},
+		{"denied command elsewhere", p, "U01", "COTHER", "flip", false, true},
+		{"author glob", p, "U0123", "COTHER", "roll", false, true},
+		{"author glob not matching", p, "U1", "COTHER", "roll", false, false},
+		{"channel list", p, "U1", "CPUBLIC", "echo", false, true},
+		{"channel glob", p, "U1", "CRANDOM", "help", false, true},
+		{"command not listed", p, "U1", "CPUBLIC", "roll", false, false},
+		{"default deny", p, "U1", "COTHER", "echo", false, false},
+		{"default allow", &Policy{}, "U1", "COTHER", "echo", false, true},
+		{"explicit default allow", &Policy{Default: Allow, Rules: []Rule{{Effect: Deny, Commands: []string{"e*"}}}}, "U1", "COTHER", "help", false, true},
+		{"admin bypasses rules", p, "UADMIN", "C0G9QF9GW", "flip", false, true},
+		{"admin only for admins", p, "UADMIN", "COTHER", "admin", true, true},
+		{"admin only denied", p, "U01", "COTHER", "admin", true, false},
+		{"nil policy", nil, "U1", "COTHER", "echo", false, true},
+		{"nil policy admin only", nil, "U1", "COTHER", "admin", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Allowed(tt.author, tt.channel, tt.command, tt.adminOnly); got != tt.want {
+				t.Errorf("Allowed(%q, %q, %q, %t) = %t, want %t", tt.author, tt.channel, tt.command, tt.adminOnly, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		want   string
+	}{
+		{"unknown default", "default: maybe", `default: unknown effect "maybe", expected "allow" or "deny"`},
+		{"missing effect", "rules:\n- commands: [echo]", "rule 0: effect is required"},
+		{"bad pattern", "rules:\n- effect: allow\n  authors: ['[']", `rule 0: invalid pattern "[": syntax error in pattern`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.policy)); err == nil || err.Error() != tt.want {
+				t.Errorf("Parse() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package acl
+
+import (
+	"context"
+	"github.com/botless/commands/pkg/logging"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// File is a policy loaded from a file and reloaded when the file changes.
+type File struct {
+	path string
+
+	mu      sync.RWMutex
+	policy  *Policy
+	modTime time.Time
+	size    int64
+}
+
+// Load reads the policy at path.
+func Load(path string) (*File, error) {
+	f := &File{path: path}
+	if _, err := f.reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Policy returns the current policy.
+func (f *File) Policy() *Policy {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.policy
+}
+
+// Allowed checks the current policy, see Policy.Allowed.
+func (f *File) Allowed(author, channel, command string, adminOnly bool) bool {
+	return f.Policy().Allowed(author, channel, command, adminOnly)
+}
+
+// Watch checks the file every interval until ctx is done, reloading it when
+// it changed. A policy that fails to load is logged and the previous policy
+// is kept.
+func (f *File) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if reloaded, err := f.reload(); err != nil {
+				logging.Default().Errorf("failed to reload acl policy %s, keeping the previous policy: %s", f.path, err)
+			} else if reloaded {
+				logging.Default().Infof("reloaded acl policy %s", f.path)
+			}
+		}
+	}
+}
+
+func (f *File) reload() (bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, err
+	}
+	f.mu.RLock()
+	unchanged := f.policy != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size
+	f.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return false, err
+	}
+	p, err := Parse(data)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err != nil {
+		// Only report a broken file once, the previous policy stays.
+		if f.policy != nil {
+			f.modTime = info.ModTime()
+			f.size = info.Size()
+		}
+		return false, err
+	}
+	f.policy = p
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	return true, nil
+}
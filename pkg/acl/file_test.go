@@ -0,0 +1,65 @@
+package acl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acl")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "acl.yaml")
+	write := func(policy string, modTime time.Time) {
+		t.Helper()
+		if err := ioutil.WriteFile(path, []byte(policy), 0600); err != nil {
+			t.Fatal(err)
+		}
+		// Set the time, writes within the resolution of the file system
+		// would not be seen as changes.
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(-time.Hour)
+
+	write("default: deny", start)
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if f.Allowed("U1", "C1", "echo", false) {
+		t.Errorf("echo allowed by the loaded policy")
+	}
+
+	// An invalid policy keeps the previous one, and is reported once.
+	write("default: maybe", start.Add(time.Minute))
+	if _, err := f.reload(); err == nil {
+		t.Errorf("reload() of an invalid policy succeeded")
+	}
+	if reloaded, err := f.reload(); reloaded || err != nil {
+		t.Errorf("second reload() = %t, %v, want unchanged", reloaded, err)
+	}
+	if f.Allowed("U1", "C1", "echo", false) {
+		t.Errorf("echo allowed after an invalid policy")
+	}
+
+	write("default: allow", start.Add(2*time.Minute))
+	if reloaded, err := f.reload(); !reloaded || err != nil {
+		t.Errorf("reload() = %t, %v, want reloaded", reloaded, err)
+	}
+	if !f.Allowed("U1", "C1", "echo", false) {
+		t.Errorf("echo denied by the reloaded policy")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	if _, err := Load(filepath.Join(os.TempDir(), "does-not-exist", "acl.yaml")); err == nil {
+		t.Errorf("Load() of a missing file succeeded")
+	}
+}
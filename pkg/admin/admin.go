@@ -0,0 +1,118 @@
+// Package admin serves the health, readiness and admin endpoints of a
+// botless commands service.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/botless/commands/pkg/commands"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+const resolveTimeout = 2 * time.Second
+
+// Server implements /healthz, /readyz and /admin/commands.
+type Server struct {
+	Registry *commands.Registry
+	// Metrics provides the per command counters. Optional.
+	Metrics *commands.Metrics
+	// Target is the URL responses are sent to. When set, the service is only
+	// ready while its host resolves.
+	Target string
+
+	ready int32
+}
+
+// SetReady marks the service as ready, or not, to receive events.
+func (s *Server) SetReady(ready bool) {
+	var v int32
+	if ready {
+		v = 1
+	}
+	atomic.StoreInt32(&s.ready, v)
+}
+
+// Register adds the endpoints to mux.
+func (s *Server) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", s.healthz)
+	mux.HandleFunc("/readyz", s.readyz)
+	mux.HandleFunc("/admin/commands", s.commands)
+}
+
+func (s *Server) healthz(w http.ResponseWriter, req *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok\n"))
+}
+
+func (s *Server) readyz(w http.ResponseWriter, req *http.Request) {
+	if atomic.LoadInt32(&s.ready) == 0 {
+		http.Error(w, "receiver not started", http.StatusServiceUnavailable)
+		return
+	}
+	if err := s.resolveTarget(req.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok\n"))
+}
+
+func (s *Server) resolveTarget(ctx context.Context) error {
+	if s.Target == "" {
+		return nil
+	}
+	u, err := url.Parse(s.Target)
+	if err != nil {
+		return fmt.Errorf("invalid target: %s", err)
+	}
+	host := u.Hostname()
+	if host == "" || net.ParseIP(host) != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	if _, err := net.DefaultResolver.LookupHost(ctx, host); err != nil {
+		return fmt.Errorf("target not resolvable: %s", err)
+	}
+	return nil
+}
+
+type commandStatus struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Description string   `json:"description,omitempty"`
+	Usage       string   `json:"usage,omitempty"`
+	Aliases     []string `json:"aliases,omitempty"`
+	Enabled     bool     `json:"enabled"`
+
+	commands.CommandCounts
+}
+
+func (s *Server) commands(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	status := []commandStatus{}
+	for _, c := range s.Registry.Commands() {
+		status = append(status, commandStatus{
+			Name:          c.Name,
+			Type:          c.Type,
+			Description:   c.Description,
+			Usage:         c.Usage,
+			Aliases:       c.Aliases,
+			Enabled:       s.Registry.Enabled(c),
+			CommandCounts: s.Metrics.Counts(c.Name),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(status)
+}
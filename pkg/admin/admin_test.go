@@ -0,0 +1,155 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/botless/commands/pkg/commands"
+	"github.com/botless/commands/pkg/logging"
+	"github.com/botless/commands/pkg/metrics"
+	"github.com/botless/events/pkg/events"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Commands log every event, only errors are worth reading here.
+	logging.SetDefault(logging.New(os.Stderr, logging.Error, false))
+	os.Exit(m.Run())
+}
+
+// testServer serves a registry of echo and flip, with the alias toss.
+func testServer(t *testing.T) (*Server, *http.ServeMux) {
+	t.Helper()
+	r := commands.NewRegistry()
+	for _, cmd := range []commands.Command{{
+		Name:        "echo",
+		Description: "Echoes.",
+		Usage:       "echo <text>",
+		Handler: commands.Text(func(args string) (string, error) {
+			return args, nil
+		}),
+	}, {
+		Name:    "flip",
+		Aliases: []string{"toss"},
+		Handler: commands.Text(func(args string) (string, error) {
+			return "heads", nil
+		}),
+	}} {
+		if err := r.Register(cmd); err != nil {
+			t.Fatalf("Register: %s", err)
+		}
+	}
+	s := &Server{Registry: r, Metrics: commands.NewMetrics(metrics.NewRegistry())}
+	mux := http.NewServeMux()
+	s.Register(mux)
+	return s, mux
+}
+
+func get(mux *http.ServeMux, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func listCommands(t *testing.T, mux *http.ServeMux) []commandStatus {
+	t.Helper()
+	w := get(mux, "/admin/commands")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /admin/commands = %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	var status []commandStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+	return status
+}
+
+func TestCommands(t *testing.T) {
+	s, mux := testServer(t)
+
+	// Handle an echo, so it has counts.
+	c := &commands.Commands{Registry: s.Registry, Metrics: s.Metrics}
+	event := cloudevents.Event{
+		Context: cloudevents.EventContextV02{
+			ID:          "1",
+			Type:        commands.CommandType("echo"),
+			Source:      *types.ParseURLRef("//test"),
+			ContentType: cloudevents.StringOfApplicationJSON(),
+		}.AsV02(),
+		Data: events.Command{Channel: "general", Author: "alice", Cmd: "echo", Args: "hi"},
+	}
+	c.ReceiveAndReply(context.Background(), event, &cloudevents.EventResponse{})
+
+	want := []commandStatus{{
+		Name:          "echo",
+		Type:          commands.CommandType("echo"),
+		Description:   "Echoes.",
+		Usage:         "echo <text>",
+		Enabled:       true,
+		CommandCounts: commands.CommandCounts{Handled: 1, SendSuccesses: 1},
+	}, {
+		Name:    "flip",
+		Type:    commands.CommandType("flip"),
+		Aliases: []string{"toss"},
+		Enabled: true,
+	}, {
+		Name:        "help",
+		Type:        commands.CommandType("help"),
+		Description: "Lists the available commands or shows how to use one.",
+		Usage:       "help [command]",
+		Enabled:     true,
+	}}
+	if got := listCommands(t, mux); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands = %+v, want %+v", got, want)
+	}
+
+	// Disabled commands are listed as such.
+	if err := s.Registry.SetEnabled("toss", false); err != nil {
+		t.Fatalf("SetEnabled: %s", err)
+	}
+	want[1].Enabled = false
+	if got := listCommands(t, mux); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands after disabling flip = %+v, want %+v", got, want)
+	}
+	if err := s.Registry.SetEnabled("flip", true); err != nil {
+		t.Fatalf("SetEnabled: %s", err)
+	}
+	want[1].Enabled = true
+	if got := listCommands(t, mux); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands after enabling flip = %+v, want %+v", got, want)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/admin/commands", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /admin/commands = %d, want 405", w.Code)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	s, mux := testServer(t)
+	if w := get(mux, "/healthz"); w.Code != http.StatusOK {
+		t.Errorf("GET /healthz = %d, want 200", w.Code)
+	}
+	if w := get(mux, "/readyz"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /readyz before ready = %d, want 503", w.Code)
+	}
+	s.SetReady(true)
+	// An IP target is not resolved.
+	s.Target = "http://127.0.0.1:8080"
+	if w := get(mux, "/readyz"); w.Code != http.StatusOK {
+		t.Errorf("GET /readyz = %d, want 200", w.Code)
+	}
+	s.Target = "http://%zz"
+	if w := get(mux, "/readyz"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /readyz with an invalid target = %d, want 503", w.Code)
+	}
+}
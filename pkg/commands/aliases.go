@@ -0,0 +1,227 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/botless/commands/pkg/store"
+	"github.com/botless/events/pkg/events"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Alias is a user defined shortcut for a command with preset arguments.
+type Alias struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+	Args    string `json:"args,omitempty"`
+}
+
+// AliasStore persists user defined aliases per channel.
+type AliasStore interface {
+	Get(ctx context.Context, channel, name string) (Alias, bool, error)
+	Set(ctx context.Context, channel string, alias Alias) error
+	Delete(ctx context.Context, channel, name string) error
+	List(ctx context.Context, channel string) ([]Alias, error)
+}
+
+// aliasNamespace is the store namespace of StoreAliases.
+const aliasNamespace = "alias"
+
+// StoreAliases is an AliasStore keeping aliases in a store.Store. Services
+// sharing the store resolve the aliases added by any of them, so the service
+// handling alias need not be the one handling the commands aliased.
+type StoreAliases struct {
+	Store store.Store
+}
+
+var _ AliasStore = (*StoreAliases)(nil)
+
+// aliasKey is the key of alias name in channel. Channels are escaped so the
+// keys of one channel share a prefix no other channel has.
+func aliasKey(channel, name string) string {
+	return url.PathEscape(channel) + "/" + name
+}
+
+func (a *StoreAliases) Get(ctx context.Context, channel, name string) (Alias, bool, error) {
+	data, found, err := a.Store.Get(ctx, aliasNamespace, aliasKey(channel, name))
+	if err != nil || !found {
+		return Alias{}, false, err
+	}
+	alias := Alias{}
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return Alias{}, false, fmt.Errorf("invalid alias %q: %s", name, err)
+	}
+	return alias, true, nil
+}
+
+func (a *StoreAliases) Set(ctx context.Context, channel string, alias Alias) error {
+	data, err := json.Marshal(alias)
+	if err != nil {
+		return err
+	}
+	return a.Store.Set(ctx, aliasNamespace, aliasKey(channel, alias.Name), data, 0)
+}
+
+func (a *StoreAliases) Delete(ctx context.Context, channel, name string) error {
+	return a.Store.Delete(ctx, aliasNamespace, aliasKey(channel, name))
+}
+
+func (a *StoreAliases) List(ctx context.Context, channel string) ([]Alias, error) {
+	entries, err := a.Store.Scan(ctx, aliasNamespace, aliasKey(channel, ""))
+	if err != nil {
+		return nil, err
+	}
+	aliases := make([]Alias, 0, len(entries))
+	for _, e := range entries {
+		alias := Alias{}
+		if err := json.Unmarshal(e.Value, &alias); err != nil {
+			return nil, fmt.Errorf("invalid alias %q: %s", e.Key, err)
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases, nil
+}
+
+// cutWord returns the first white space separated word of s and the rest of
+// s after it, both trimmed.
+func cutWord(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexFunc(s, unicode.IsSpace); i >= 0 {
+		return s[:i], strings.TrimSpace(s[i:])
+	}
+	return s, ""
+}
+
+var aliasName = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// AliasCommand manages the user defined aliases in aliases. Aliases may not
+// shadow the commands or static aliases in r.
+func AliasCommand(r *Registry, aliases AliasStore) Command {
+	return Command{
+		Name:        "alias",
+		Description: "Adds, removes or lists the command aliases of this channel.",
+		Usage:       "alias add <name> <command> [args...] | alias remove <name> | alias list",
+		Examples: []string{
+			"alias add lol echo ¯\\_(ツ)_/¯",
+			"alias remove lol",
+			"alias list",
+		},
+		Handler: func(ctx context.Context, req *Request, resp Responder) {
+			// The preset arguments are kept as typed, they are parsed by the
+			// aliased command each time the alias runs.
+			action, rest := cutWord(req.Args)
+			switch action {
+			case "add":
+				word, rest := cutWord(rest)
+				command, preset := cutWord(rest)
+				if command == "" {
+					resp.ReplyError(fmt.Errorf("usage: `alias add <name> <command> [args...]`"))
+					return
+				}
+				name := strings.ToLower(word)
+				if !aliasName.MatchString(name) {
+					resp.ReplyError(fmt.Errorf("invalid alias name %q, use lower case letters, digits, - and _", word))
+					return
+				}
+				if _, found := r.Lookup(name); found {
+					resp.ReplyError(fmt.Errorf("%q is already a command", name))
+					return
+				}
+				target, found := r.Lookup(command)
+				if !found {
+					resp.ReplyError(fmt.Errorf("unknown command %q", command))
+					return
+				}
+				alias := Alias{
+					Name:    name,
+					Command: target.Name,
+					Args:    preset,
+				}
+				if err := aliases.Set(ctx, req.Channel, alias); err != nil {
+					resp.ReplyError(fmt.Errorf("failed to save alias: %s", err))
+					return
+				}
+				resp.Reply(fmt.Sprintf("`%s` now runs `%s`", alias.Name, strings.TrimSpace(alias.Command+" "+alias.Args)))
+			case "remove", "rm":
+				word, extra := cutWord(rest)
+				if word == "" || extra != "" {
+					resp.ReplyError(fmt.Errorf("usage: `alias remove <name>`"))
+					return
+				}
+				name := strings.ToLower(word)
+				if _, found, err := aliases.Get(ctx, req.Channel, name); err != nil {
+					resp.ReplyError(err)
+					return
+				} else if !found {
+					resp.ReplyError(fmt.Errorf("no alias %q in this channel", name))
+					return
+				}
+				if err := aliases.Delete(ctx, req.Channel, name); err != nil {
+					resp.ReplyError(fmt.Errorf("failed to remove alias: %s", err))
+					return
+				}
+				resp.Reply(fmt.Sprintf("removed alias `%s`", name))
+			case "", "list", "ls":
+				all, err := aliases.List(ctx, req.Channel)
+				if err != nil {
+					resp.ReplyError(err)
+					return
+				}
+				if len(all) == 0 {
+					resp.Reply("no aliases in this channel, add one with `alias add <name> <command> [args...]`")
+					return
+				}
+				sb := strings.Builder{}
+				sb.WriteString("Aliases in this channel:")
+				for _, a := range all {
+					sb.WriteString(fmt.Sprintf("\n• `%s` → `%s`", a.Name, strings.TrimSpace(a.Command+" "+a.Args)))
+				}
+				resp.Reply(sb.String())
+			default:
+				resp.ReplyError(fmt.Errorf("unknown action %q, expected add, remove or list", action))
+			}
+		},
+	}
+}
+
+// resolveAlias finds the command a user defined alias in the channel of
+// event points to.
+func (c *Commands) resolveAlias(ctx context.Context, event cloudevents.Event) (*Command, *Alias, bool) {
+	if c.Aliases == nil {
+		return nil, nil, false
+	}
+	prefix := CommandType("")
+	if !strings.HasPrefix(event.Type(), prefix) {
+		return nil, nil, false
+	}
+	cmd := events.Command{}
+	if err := event.DataAs(&cmd); err != nil {
+		return nil, nil, false
+	}
+	return c.lookupAlias(ctx, cmd.Channel, strings.TrimPrefix(event.Type(), prefix))
+}
+
+// lookupAlias finds the user defined alias name in channel and the command it
+// points to.
+func (c *Commands) lookupAlias(ctx context.Context, channel, name string) (*Command, *Alias, bool) {
+	if c.Aliases == nil {
+		return nil, nil, false
+	}
+	alias, found, err := c.Aliases.Get(ctx, channel, strings.ToLower(name))
+	if err != nil {
+		c.logger().Errorf("failed to get alias %q: %s", name, err)
+		return nil, nil, false
+	}
+	if !found {
+		return nil, nil, false
+	}
+	target, found := c.registry().Lookup(alias.Command)
+	if !found {
+		return nil, nil, false
+	}
+	return target, &alias, true
+}
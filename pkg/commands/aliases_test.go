@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"github.com/botless/commands/pkg/store"
+	"testing"
+)
+
+func TestStaticAliasFilter(t *testing.T) {
+	c := &Commands{
+		Registry: testRegistry(t),
+		Filter:   mustFilter(t, CommandType("caps")),
+	}
+	for _, tt := range []struct {
+		text, want string
+	}{
+		{"caps hi", "HI"},
+		{"shout hi", "HI"},
+		{"echo hi", ""},
+	} {
+		if got := reply(t, c, "c1", tt.text); got != tt.want {
+			t.Errorf("%q replied %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestUserAliases(t *testing.T) {
+	shared := store.NewMemory()
+	// The service handling alias is not the one handling the aliased
+	// command, they share the store.
+	aliasRegistry := testRegistry(t)
+	if err := aliasRegistry.Register(AliasCommand(aliasRegistry, &StoreAliases{Store: shared})); err != nil {
+		t.Fatalf("Register: %s", err)
+	}
+	admin := &Commands{
+		Registry: aliasRegistry,
+		Filter:   mustFilter(t, CommandType("alias")),
+		Aliases:  &StoreAliases{Store: shared},
+	}
+	echo := &Commands{
+		Registry: testRegistry(t),
+		Filter:   mustFilter(t, CommandType("echo")),
+		Aliases:  &StoreAliases{Store: shared},
+	}
+
+	for _, tt := range []struct {
+		c          *Commands
+		channel    string
+		text, want string
+	}{
+		{admin, "c1", `alias add lol echo ¯\_(ツ)_/¯`, "`lol` now runs `echo ¯\\_(ツ)_/¯`"},
+		{admin, "c1", "alias add Hi echo  'hello  there' ", "`hi` now runs `echo 'hello  there'`"},
+		{echo, "c1", "lol", `¯\_(ツ)_/¯`},
+		{echo, "c1", "lol again", `¯\_(ツ)_/¯ again`},
+		{echo, "c1", "hi", "'hello  there'"},
+		// Aliases are per channel.
+		{echo, "c2", "lol", ""},
+		{admin, "c1", "alias add echo caps", `alias: "echo" is already a command`},
+		{admin, "c1", "alias add x nope", `alias: unknown command "nope"`},
+		{admin, "c1", "alias add x", "alias: usage: `alias add <name> <command> [args...]`"},
+		{admin, "c1", "alias list", "Aliases in this channel:\n• `hi` → `echo 'hello  there'`\n• `lol` → `echo ¯\\_(ツ)_/¯`"},
+		{admin, "c2", "alias", "no aliases in this channel, add one with `alias add <name> <command> [args...]`"},
+		{admin, "c1", "alias rm lol", "removed alias `lol`"},
+		{admin, "c1", "alias rm lol", `alias: no alias "lol" in this channel`},
+		{echo, "c1", "lol", ""},
+	} {
+		if got := reply(t, tt.c, tt.channel, tt.text); got != tt.want {
+			t.Errorf("%q in %s replied %q, want %q", tt.text, tt.channel, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package args
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is the type of value a flag or positional argument holds.
+type Kind int
+
+const (
+	String Kind = iota
+	Int
+	Bool
+	Duration
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Int:
+		return "int"
+	case Bool:
+		return "bool"
+	case Duration:
+		return "duration"
+	default:
+		return "string"
+	}
+}
+
+func (k Kind) parse(s string) (interface{}, error) {
+	switch k {
+	case Int:
+		return strconv.Atoi(s)
+	case Bool:
+		return strconv.ParseBool(s)
+	case Duration:
+		return time.ParseDuration(s)
+	default:
+		return s, nil
+	}
+}
+
+// Flag declares an option given as --name=value, --name value, -s value or,
+// for Bool flags, just --name or -s.
+type Flag struct {
+	Name    string
+	Short   string
+	Kind    Kind
+	Default string
+	Usage   string
+}
+
+// Arg declares a positional argument. Only the last Arg may be Variadic, in
+// which case it collects all remaining words.
+type Arg struct {
+	Name     string
+	Kind     Kind
+	Required bool
+	Variadic bool
+	Usage    string
+}
+
+// Schema declares the arguments a command accepts.
+type Schema struct {
+	Flags []Flag
+	Args  []Arg
+}
+
+// UsageError is returned when the input does not match the Schema.
+type UsageError struct {
+	Reason string
+}
+
+func (e *UsageError) Error() string {
+	return e.Reason
+}
+
+func usageErrorf(format string, a ...interface{}) error {
+	return &UsageError{Reason: fmt.Sprintf(format, a...)}
+}
+
+func (s *Schema) flag(name string) *Flag {
+	for i, f := range s.Flags {
+		if f.Name == name {
+			return &s.Flags[i]
+		}
+	}
+	return nil
+}
+
+func (s *Schema) short(name string) *Flag {
+	for i, f := range s.Flags {
+		if f.Short != "" && f.Short == name {
+			return &s.Flags[i]
+		}
+	}
+	return nil
+}
+
+// Parse splits raw with Split and matches the words against the schema.
+func (s *Schema) Parse(raw string) (*Values, error) {
+	words, err := Split(raw)
+	if err != nil {
+		return nil, &UsageError{Reason: err.Error()}
+	}
+	return s.ParseWords(words)
+}
+
+// ParseWords matches already split words against the schema.
+func (s *Schema) ParseWords(words []string) (*Values, error) {
+	v := &Values{
+		values: make(map[string]interface{}),
+	}
+	for _, f := range s.Flags {
+		if f.Default == "" {
+			continue
+		}
+		d, err := f.Kind.parse(f.Default)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default for --%s: %s", f.Name, err)
+		}
+		v.values[f.Name] = d
+	}
+
+	var positional []string
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		switch {
+		case w == "--":
+			positional = append(positional, words[i+1:]...)
+			i = len(words)
+		case strings.HasPrefix(w, "--") && len(w) > 2:
+			name, value, hasValue := cut(w[2:])
+			f := s.flag(name)
+			if f == nil {
+				return nil, usageErrorf("unknown flag --%s", name)
+			}
+			if !hasValue {
+				if f.Kind == Bool {
+					value = "true"
+				} else if i+1 < len(words) {
+					i++
+					value = words[i]
+				} else {
+					return nil, usageErrorf("flag --%s needs a value (%s)", name, f.Kind)
+				}
+			}
+			if err := v.set(f, value); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(w, "-") && len(w) > 1 && !isNumber(w):
+			name, value, hasValue := cut(w[1:])
+			if !hasValue && len(name) > 1 {
+				// -abc is short for -a -b -c when all are booleans.
+				for _, r := range name {
+					f := s.short(string(r))
+					if f == nil {
+						return nil, usageErrorf("unknown flag -%c", r)
+					}
+					if f.Kind != Bool {
+						return nil, usageErrorf("flag -%c needs a value (%s)", r, f.Kind)
+					}
+					v.values[f.Name] = true
+				}
+				continue
+			}
+			f := s.short(name)
+			if f == nil {
+				return nil, usageErrorf("unknown flag -%s", name)
+			}
+			if !hasValue {
+				if f.Kind == Bool {
+					value = "true"
+				} else if i+1 < len(words) {
+					i++
+					value = words[i]
+				} else {
+					return nil, usageErrorf("flag -%s needs a value (%s)", name, f.Kind)
+				}
+			}
+			if err := v.set(f, value); err != nil {
+				return nil, err
+			}
+		default:
+			positional = append(positional, w)
+		}
+	}
+
+	v.positional = positional
+	for i, a := range s.Args {
+		if a.Variadic {
+			rest := []string(nil)
+			if i < len(positional) {
+				rest = positional[i:]
+			}
+			if a.Required && len(rest) == 0 {
+				return nil, usageErrorf("missing <%s>", a.Name)
+			}
+			for _, w := range rest {
+				if _, err := a.Kind.parse(w); err != nil {
+					return nil, usageErrorf("invalid %s %q for <%s>", a.Kind, w, a.Name)
+				}
+			}
+			v.values[a.Name] = strings.Join(rest, " ")
+			return v, nil
+		}
+		if i >= len(positional) {
+			if a.Required {
+				return nil, usageErrorf("missing <%s>", a.Name)
+			}
+			continue
+		}
+		pv, err := a.Kind.parse(positional[i])
+		if err != nil {
+			return nil, usageErrorf("invalid %s %q for <%s>", a.Kind, positional[i], a.Name)
+		}
+		v.values[a.Name] = pv
+	}
+	if len(positional) > len(s.Args) {
+		return nil, usageErrorf("unexpected argument %q", positional[len(s.Args)])
+	}
+	return v, nil
+}
+
+// Usage renders a one line synopsis of the schema, e.g.
+// "[--times=int] [-l] <text>...".
+func (s *Schema) Usage() string {
+	var parts []string
+	for _, f := range s.Flags {
+		name := "--" + f.Name
+		if f.Short != "" {
+			name = "-" + f.Short + "|" + name
+		}
+		if f.Kind != Bool {
+			name += "=" + f.Kind.String()
+		}
+		parts = append(parts, "["+name+"]")
+	}
+	for _, a := range s.Args {
+		name := "<" + a.Name + ">"
+		if a.Variadic {
+			name += "..."
+		}
+		if !a.Required {
+			name = "[" + name + "]"
+		}
+		parts = append(parts, name)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Help renders one line per flag and argument that has a usage string.
+func (s *Schema) Help() string {
+	var lines []string
+	for _, f := range s.Flags {
+		if f.Usage == "" {
+			continue
+		}
+		name := "--" + f.Name
+		if f.Short != "" {
+			name = "-" + f.Short + ", " + name
+		}
+		line := fmt.Sprintf("  `%s` %s", name, f.Usage)
+		if f.Default != "" {
+			line += fmt.Sprintf(" (default %s)", f.Default)
+		}
+		lines = append(lines, line)
+	}
+	for _, a := range s.Args {
+		if a.Usage == "" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  `<%s>` %s", a.Name, a.Usage))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (v *Values) set(f *Flag, value string) error {
+	pv, err := f.Kind.parse(value)
+	if err != nil {
+		return usageErrorf("invalid %s %q for --%s", f.Kind, value, f.Name)
+	}
+	v.values[f.Name] = pv
+	return nil
+}
+
+func cut(s string) (string, string, bool) {
+	if i := strings.Index(s, "="); i >= 0 {
+		return s[:i], s[i+1:], true
+	}
+	return s, "", false
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
@@ -0,0 +1,162 @@
+package args
+
+import (
+	"testing"
+	"time"
+)
+
+var testSchema = &Schema{
+	Flags: []Flag{
+		{Name: "times", Short: "n", Kind: Int, Default: "1"},
+		{Name: "loud", Short: "l", Kind: Bool},
+		{Name: "quiet", Short: "q", Kind: Bool},
+		{Name: "every", Kind: Duration},
+		{Name: "prefix", Short: "p", Kind: String},
+	},
+	Args: []Arg{
+		{Name: "count", Kind: Int, Required: true},
+		{Name: "text", Variadic: true},
+	},
+}
+
+func TestParseWords(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		check func(t *testing.T, v *Values)
+		err   string
+	}{{
+		name:  "defaults",
+		words: []string{"3"},
+		check: func(t *testing.T, v *Values) {
+			if got := v.Int("times"); got != 1 {
+				t.Errorf("times = %d, want 1", got)
+			}
+			if v.Has("loud") {
+				t.Errorf("loud is set")
+			}
+			if got := v.Int("count"); got != 3 {
+				t.Errorf("count = %d, want 3", got)
+			}
+			if got := v.String("text"); got != "" {
+				t.Errorf("text = %q, want empty", got)
+			}
+		},
+	}, {
+		name:  "long flags",
+		words: []string{"--times=4", "--every", "1m", "--loud", "2", "hello", "world"},
+		check: func(t *testing.T, v *Values) {
+			if got := v.Int("times"); got != 4 {
+				t.Errorf("times = %d, want 4", got)
+			}
+			if got := v.Duration("every"); got != time.Minute {
+				t.Errorf("every = %s, want 1m", got)
+			}
+			if !v.Bool("loud") {
+				t.Errorf("loud is not set")
+			}
+			if got := v.String("text"); got != "hello world" {
+				t.Errorf("text = %q, want %q", got, "hello world")
+			}
+		},
+	}, {
+		name:  "short flags",
+		words: []string{"-n", "5", "-p=>", "-lq", "1"},
+		check: func(t *testing.T, v *Values) {
+			if got := v.Int("times"); got != 5 {
+				t.Errorf("times = %d, want 5", got)
+			}
+			if got := v.String("prefix"); got != ">" {
+				t.Errorf("prefix = %q, want >", got)
+			}
+			if !v.Bool("loud") || !v.Bool("quiet") {
+				t.Errorf("loud and quiet are not both set")
+			}
+		},
+	}, {
+		name:  "unknown short flag",
+		words: []string{"-2", "-x"},
+		err:   "unknown flag -x",
+	}, {
+		name:  "negative number",
+		words: []string{"-2", "a"},
+		check: func(t *testing.T, v *Values) {
+			if got := v.Int("count"); got != -2 {
+				t.Errorf("count = %d, want -2", got)
+			}
+		},
+	}, {
+		name:  "double dash ends flags",
+		words: []string{"1", "--", "--loud", "-n"},
+		check: func(t *testing.T, v *Values) {
+			if v.Bool("loud") {
+				t.Errorf("loud is set")
+			}
+			if got := v.String("text"); got != "--loud -n" {
+				t.Errorf("text = %q, want %q", got, "--loud -n")
+			}
+			if got := len(v.Positional()); got != 3 {
+				t.Errorf("len(Positional()) = %d, want 3", got)
+			}
+		},
+	}, {
+		name:  "missing required",
+		words: nil,
+		err:   "missing <count>",
+	}, {
+		name:  "invalid positional",
+		words: []string{"three"},
+		err:   `invalid int "three" for <count>`,
+	}, {
+		name:  "unknown long flag",
+		words: []string{"--nope", "1"},
+		err:   "unknown flag --nope",
+	}, {
+		name:  "flag without value",
+		words: []string{"1", "--times"},
+		err:   "flag --times needs a value (int)",
+	}, {
+		name:  "invalid flag value",
+		words: []string{"--every=soon", "1"},
+		err:   `invalid duration "soon" for --every`,
+	}, {
+		name:  "grouped non-bool",
+		words: []string{"-ln", "1"},
+		err:   "flag -n needs a value (int)",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := testSchema.ParseWords(tt.words)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("ParseWords(%q) error = %v, want %q", tt.words, err, tt.err)
+				}
+				if _, ok := err.(*UsageError); !ok {
+					t.Errorf("error is %T, want *UsageError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseWords(%q) unexpected error: %s", tt.words, err)
+			}
+			tt.check(t, v)
+		})
+	}
+}
+
+func TestParseTooManyArgs(t *testing.T) {
+	s := &Schema{Args: []Arg{{Name: "a"}}}
+	if _, err := s.Parse("x y"); err == nil || err.Error() != `unexpected argument "y"` {
+		t.Errorf("Parse error = %v, want unexpected argument", err)
+	}
+	if _, err := s.Parse(`"x`); err == nil {
+		t.Errorf("Parse of unterminated quote succeeded")
+	}
+}
+
+func TestUsage(t *testing.T) {
+	want := "[-n|--times=int] [-l|--loud] [-q|--quiet] [--every=duration] [-p|--prefix=string] <count> [<text>...]"
+	if got := testSchema.Usage(); got != want {
+		t.Errorf("Usage() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+package args
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Split breaks s into words using shell-style rules: words are separated by
+// unquoted white space, single quotes preserve everything literally, double
+// quotes allow \" and \\ escapes, and a backslash outside quotes escapes the
+// next character.
+func Split(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			switch {
+			case r == '"':
+				quote = 0
+			case r == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\'):
+				i++
+				word.WriteRune(runes[i])
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\':
+			if i+1 == len(runes) {
+				return nil, fmt.Errorf("trailing backslash")
+			}
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// SplitPipeline breaks s into the stages of a pipeline at each unquoted |.
+// Stages are returned trimmed but otherwise unparsed, except that \| is
+// replaced with a literal |. Every stage after the first must be non-empty.
+func SplitPipeline(s string) ([]string, error) {
+	var stages []string
+	var stage strings.Builder
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == '\\' && quote == '"' && i+1 < len(runes) {
+				stage.WriteRune(r)
+				i++
+				r = runes[i]
+			}
+			stage.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			stage.WriteRune(r)
+		case r == '\\' && i+1 < len(runes):
+			i++
+			if runes[i] != '|' {
+				stage.WriteRune(r)
+			}
+			stage.WriteRune(runes[i])
+		case r == '|':
+			stages = append(stages, strings.TrimSpace(stage.String()))
+			stage.Reset()
+		default:
+			stage.WriteRune(r)
+		}
+	}
+	stages = append(stages, strings.TrimSpace(stage.String()))
+	for i, st := range stages[1:] {
+		if st == "" {
+			return nil, fmt.Errorf("pipeline stage %d is empty", i+2)
+		}
+	}
+	return stages, nil
+}
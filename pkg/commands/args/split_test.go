@@ -0,0 +1,84 @@
+package args
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+		err  string
+	}{
+		{in: "", want: nil},
+		{in: "   ", want: nil},
+		{in: "a b  c", want: []string{"a", "b", "c"}},
+		{in: " a\tb\nc ", want: []string{"a", "b", "c"}},
+		{in: `'a b' c`, want: []string{"a b", "c"}},
+		{in: `"a b" c`, want: []string{"a b", "c"}},
+		{in: `'a \" b'`, want: []string{`a \" b`}},
+		{in: `"a \" \\ \n"`, want: []string{`a " \ \n`}},
+		{in: `a\ b`, want: []string{"a b"}},
+		{in: `a'b'"c"`, want: []string{"abc"}},
+		{in: `''`, want: []string{""}},
+		{in: `a "" b`, want: []string{"a", "", "b"}},
+		{in: `héllo wörld`, want: []string{"héllo", "wörld"}},
+		{in: `a\`, err: "trailing backslash"},
+		{in: `'a`, err: "unterminated ' quote"},
+		{in: `"a`, err: `unterminated " quote`},
+	}
+	for _, tt := range tests {
+		got, err := Split(tt.in)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("Split(%q) error = %v, want %q", tt.in, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Split(%q) unexpected error: %s", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Split(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplitPipeline(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+		err  string
+	}{
+		{in: "", want: []string{""}},
+		{in: "hi", want: []string{"hi"}},
+		{in: "hi | caps | flip", want: []string{"hi", "caps", "flip"}},
+		{in: "a|b", want: []string{"a", "b"}},
+		{in: "| caps", want: []string{"", "caps"}},
+		{in: `'a | b' | caps`, want: []string{`'a | b'`, "caps"}},
+		{in: `"a | \" b" | caps`, want: []string{`"a | \" b"`, "caps"}},
+		{in: `a \| b`, want: []string{"a | b"}},
+		{in: `a \n b`, want: []string{`a \n b`}},
+		{in: `a \`, want: []string{`a \`}},
+		{in: "a |", err: "pipeline stage 2 is empty"},
+		{in: "a | | b", err: "pipeline stage 2 is empty"},
+	}
+	for _, tt := range tests {
+		got, err := SplitPipeline(tt.in)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("SplitPipeline(%q) error = %v, want %q", tt.in, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("SplitPipeline(%q) unexpected error: %s", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitPipeline(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package args
+
+import (
+	"time"
+)
+
+// Values holds the result of parsing a command's arguments against a Schema.
+// Flags and positional arguments are looked up by their declared name. A
+// variadic argument is returned as its words joined by a single space.
+type Values struct {
+	values     map[string]interface{}
+	positional []string
+}
+
+// Has reports whether name was given or has a default.
+func (v *Values) Has(name string) bool {
+	_, ok := v.values[name]
+	return ok
+}
+
+func (v *Values) String(name string) string {
+	s, _ := v.values[name].(string)
+	return s
+}
+
+func (v *Values) Int(name string) int {
+	i, _ := v.values[name].(int)
+	return i
+}
+
+func (v *Values) Bool(name string) bool {
+	b, _ := v.values[name].(bool)
+	return b
+}
+
+func (v *Values) Duration(name string) time.Duration {
+	d, _ := v.values[name].(time.Duration)
+	return d
+}
+
+// Positional returns all non-flag words in order.
+func (v *Values) Positional() []string {
+	return v.positional
+}
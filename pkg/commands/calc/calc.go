@@ -0,0 +1,77 @@
+// Package calc implements the calc command, a calculator for arithmetic with
+// exact big integers and fractions, functions and unit conversions, e.g.
+// "2^100", "sqrt(2) * 3" or "5 km to mi". Expressions are interpreted within
+// limits on their size, nesting and the numbers they produce.
+package calc
+
+import (
+	"fmt"
+	"github.com/botless/commands/pkg/commands"
+	"strings"
+)
+
+func init() {
+	commands.MustRegister(commands.Command{
+		Name:        "calc",
+		Description: "Calculates an expression, with units, e.g. 2^64, sqrt(2) or 5 km to mi.",
+		Usage:       "calc <expression> [to <unit>]",
+		Examples: []string{
+			"calc (1 + 2) * 3",
+			"calc 2^100",
+			"calc max(3, 0xff, 0b101)",
+			"calc 5 km to mi",
+			"calc 2GiB in MB",
+			"calc 255 to hex",
+		},
+		Handler: commands.Text(Text),
+	})
+}
+
+// Eval evaluates expression and formats the result.
+func Eval(expression string) (string, error) {
+	if len(expression) > maxInput {
+		return "", fmt.Errorf("expression is longer than %d characters", maxInput)
+	}
+	tokens, err := lex(expression)
+	if err != nil {
+		return "", err
+	}
+	if len(tokens) == 0 {
+		return "", fmt.Errorf("nothing to calculate")
+	}
+	p := &interpreter{tokens: tokens}
+	res, err := p.input()
+	if err != nil {
+		return "", err
+	}
+	format := res.format
+	if format == "" {
+		format = map[int]string{2: "bin", 8: "oct", 16: "hex"}[p.radix]
+	}
+	if n, ok := res.value.integer(); ok && format != "" && format != "dec" && res.value.unit == nil {
+		prefixed := map[string]string{
+			"bin": "0b" + n.Text(2),
+			"oct": "0o" + n.Text(8),
+			"hex": "0x" + n.Text(16),
+		}[format]
+		if n.Sign() < 0 {
+			prefixed = "-" + strings.Replace(prefixed, "-", "", 1)
+		}
+		if res.format != "" {
+			return prefixed, nil
+		}
+		return fmt.Sprintf("%s (%s)", res.value, prefixed), nil
+	}
+	return res.value.String(), nil
+}
+
+// Text is the handler of the calc command, replying with the expression and
+// its result.
+func Text(args string) (string, error) {
+	expression := strings.TrimSpace(args)
+	result, err := Eval(expression)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("`%s` = *%s*", expression, result), nil
+}
@@ -0,0 +1,189 @@
+package calc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		// Arithmetic is exact where it can be.
+		{"1 + 2", "3"},
+		{"(1 + 2) * 3", "9"},
+		{"1 + 2 * 3", "7"},
+		{"2 * -3", "-6"},
+		{"--3", "3"},
+		{"+3", "3"},
+		{"7 / 2", "3.5"},
+		{"1 / 3", "0.333333333333"},
+		{"1/3 * 3", "1"},
+		{"0.1 + 0.2", "0.3"},
+		{"7 % 3", "1"},
+		{"-7 % 3", "-1"},
+		{"7.5 % 2", "1.5"},
+		{"2 ^ 10", "1024"},
+		{"2 ** 10", "1024"},
+		{"2 ^ 3 ^ 2", "512"},
+		{"-2 ^ 2", "-4"},
+		{"2 ^ -2", "0.25"},
+		{"(1/2) ^ 2", "0.25"},
+		{"2 ^ 0.5", "1.41421356237"},
+		{"2^100", "1267650600228229401496703205376"},
+		{"10^1001", "1e+1001"},
+		{"2^40000 * 2^40000 * 2^40000", "3.97630489427e+36123"},
+		{"6 × 7 ÷ 2", "21"},
+		{"1_000_000 * 3", "3000000"},
+		{"1.5e3", "1500"},
+		{"1e-3", "0.001"},
+		{".5 + .25", "0.75"},
+
+		// Other bases are kept for integer results.
+		{"0xff", "255 (0xff)"},
+		{"0xff + 1", "256 (0x100)"},
+		{"0b101 * 2", "10 (0b1010)"},
+		{"0o17", "15 (0o17)"},
+		{"-0x10", "-16 (-0x10)"},
+		{"0x10 / 3", "5.33333333333"},
+		{"255 to hex", "0xff"},
+		{"5 as bin", "0b101"},
+		{"-255 in hex", "-0xff"},
+		{"0xff to dec", "255"},
+
+		// Constants and functions.
+		{"pi", "3.14159265359"},
+		{"2 * π", "6.28318530718"},
+		{"tau / 2 - pi", "0"},
+		{"e", "2.71828182846"},
+		{"sqrt(16)", "4"},
+		{"sqrt(1/4)", "0.5"},
+		{"sqrt(2)", "1.41421356237"},
+		{"sqrt(2^200)", "1267650600228229401496703205376"},
+		{"abs(-3)", "3"},
+		{"abs(-3 km)", "3 km"},
+		{"ln(e)", "1"},
+		{"log(100, 10)", "2"},
+		{"log10(1000)", "3"},
+		{"log2(8)", "3"},
+		{"exp(0)", "1"},
+		{"sin(0)", "0"},
+		{"cos(0)", "1"},
+		{"floor(7/2)", "3"},
+		{"ceil(7/2)", "4"},
+		{"round(5/2)", "3"},
+		{"floor(-7/2)", "-4"},
+		{"round(2.4 km)", "2 km"},
+		{"min(3, 1, 2)", "1"},
+		{"max(3, 0xff, 0b101)", "255 (0xff)"},
+		{"max(1 km, 900 m)", "1 km"},
+		{"min(1 km, 900 m)", "0.9 km"},
+		{"MAX(1, 2)", "2"},
+
+		// Units.
+		{"5 km to m", "5000 m"},
+		{"5 km to mi", "3.10685596119 mi"},
+		{"1 mi in km", "1.609344 km"},
+		{"12 in in cm", "30.48 cm"},
+		{"5 ft in m", "1.524 m"},
+		{"1 km + 500 m", "1.5 km"},
+		{"1 km - 500 m to m", "500 m"},
+		{"2 * 3 km", "6 km"},
+		{"3 km * 2", "6 km"},
+		{"3 km / 2", "1.5 km"},
+		{"2GiB in MB", "2147.483648 MB"},
+		{"1 MiB to KiB", "1024 KiB"},
+		{"1 byte to bits", "8 bit"},
+		{"100 °C to °F", "212 °F"},
+		{"32 F to C", "0 °C"},
+		{"0 K to celsius", "-273.15 °C"},
+		{"1 h to min", "60 min"},
+		{"1 day to s", "86400 s"},
+		{"1 gal to L", "3.785411784 L"},
+		{"1 lb to kg", "0.45359237 kg"},
+		{"km to m", "1000 m"},
+		{"5 km", "5 km"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got, err := Eval(tt.expression)
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Eval() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"", "nothing to calculate"},
+		{"   ", "nothing to calculate"},
+		{"1 +", "unexpected end of input, expected a number"},
+		{"1 2", `unexpected "2" at position 3`},
+		{"(1 + 2", "expected ) to close ( at position 1, got end of input"},
+		{"1 + 2)", `unexpected ")" at position 6`},
+		{"1 $ 2", `unexpected '$' at position 3`},
+		{"foo", `unknown name "foo" at position 1`},
+		{"foo(1)", `unknown function "foo" at position 1`},
+		{"sqrt(1, 2)", "sqrt takes 1 argument"},
+		{"log()", "log takes 1 to 2 arguments"},
+		{"max()", "max takes at least 1 argument"},
+		{"sqrt(4", "expected ) to close sqrt( at position 1, got end of input"},
+		{"1 / 0", "division by zero"},
+		{"1 % 0", "division by zero"},
+		{"0 ^ -1", "division by zero"},
+		{"sqrt(-1)", "sqrt: expected a number of at least 0, got -1"},
+		{"ln(0)", "ln: expected a number above 0, got 0"},
+		{"log(8, 1)", "log: base 1 has no logarithm"},
+		{"sin(1 m)", "sin: expected a plain number, got 1 m"},
+		{"10 ^ 1000.5", "result is too large"},
+		// Products beyond maxBits are floats, not huge exact integers.
+		{"2^40000 * 2^40000 * 2^40000 * 2^40000", "result is too large"},
+		{strings.TrimSuffix(strings.Repeat("3^27000*", 90), "*"), "result is too large"},
+		{"1e1001", `exponent of "1e1001" at position 1 is out of range`},
+		{"0x", `unexpected "x" at position 2`},
+		{"5 km to kg", "can not convert km (length) to kg (mass)"},
+		{"5 to km", "5 has no unit to convert to km"},
+		{"5 km to furlongs", `unknown unit "furlongs"`},
+		{"5 km to", `expected a unit after "to"`},
+		{"1 km + 1", "can not combine 1 km and 1, only one has a unit"},
+		{"1 km * 1 m", "can not multiply 1 km by 1 m, units can only be scaled by plain numbers"},
+		{"1 / 2 m", "can not divide 1 by 2 m, units can only be scaled by plain numbers"},
+		{"2 m ^ 2", "can not raise units to a power"},
+		{"10 °C + 5 °C", "temperatures in °C can only be converted"},
+		{"2 * 10 °C", "temperatures in °C can only be converted"},
+		{"1.5 to hex", "only integers can be shown in hex"},
+		{"5 km to hex", "can not show km in hex"},
+		// mb is ambiguous, megabits or megabytes.
+		{"1 mb", `unexpected "mb" at position 3`},
+		{strings.Repeat("(", maxDepth+1) + "1" + strings.Repeat(")", maxDepth+1), "expression is nested too deeply"},
+		{strings.Repeat("1+", 300) + "1", "expression is too long"},
+		{strings.Repeat("1", maxInput+1), "expression is longer than 1000 characters"},
+	}
+	for _, tt := range tests {
+		name := tt.expression
+		if len(name) > 20 {
+			name = name[:20] + "…"
+		}
+		t.Run(name, func(t *testing.T) {
+			got, err := Eval(tt.expression)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Eval() = %q, %v, want error %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	got, err := Text("  2^10 ")
+	if want := "`2^10` = *1024*"; err != nil || got != want {
+		t.Errorf("Text() = %q, %v, want %q", got, err, want)
+	}
+}
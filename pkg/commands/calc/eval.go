@@ -0,0 +1,421 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+const (
+	// maxInput is the longest expression evaluated.
+	maxInput = 1000
+	// maxTokens bounds the tokens of an expression.
+	maxTokens = 500
+	// maxDepth bounds the nesting of parentheses and function calls.
+	maxDepth = 64
+	// maxBits bounds the size of exact numbers, larger results are computed
+	// as floats.
+	maxBits = 1 << 17
+	// maxDigits is the longest integer printed in full.
+	maxDigits = 1000
+	// maxExponent bounds exponents written in numbers, e.g. 1e300.
+	maxExponent = 1000
+)
+
+var constants = map[string]float64{
+	"pi":  math.Pi,
+	"π":   math.Pi,
+	"e":   math.E,
+	"tau": 2 * math.Pi,
+	"phi": math.Phi,
+}
+
+// interpreter evaluates the tokens of one expression while parsing them, by
+// recursive descent over the grammar
+//
+//	input   = expr [ ("to" | "in" | "as") target ]
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/" | "%") unary }
+//	unary   = ("-" | "+") unary | power
+//	power   = postfix [ "^" unary ]
+//	postfix = primary [ unit ]
+//	primary = number | constant | unit | function "(" expr { "," expr } ")" | "(" expr ")"
+type interpreter struct {
+	tokens []token
+	pos    int
+	depth  int
+	// radix is the base of the first integer literal not written in base
+	// 10, so integer results are shown in that base too.
+	radix int
+}
+
+func (p *interpreter) peek() token {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return token{kind: tokEOF, pos: -1}
+}
+
+func (p *interpreter) peekAt(i int) token {
+	if p.pos+i < len(p.tokens) {
+		return p.tokens[p.pos+i]
+	}
+	return token{kind: tokEOF, pos: -1}
+}
+
+func (p *interpreter) next() token {
+	t := p.peek()
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func unexpected(t token) error {
+	return fmt.Errorf("unexpected %s", t.describe())
+}
+
+// result is an evaluated input.
+type result struct {
+	value value
+	// format is "hex", "bin" or "oct" when asked for with "to".
+	format string
+}
+
+func (p *interpreter) input() (result, error) {
+	v, err := p.expr()
+	if err != nil {
+		return result{}, err
+	}
+	res := result{value: v}
+	if t := p.peek(); t.kind == tokIdent && isConversion(t.text) {
+		p.next()
+		target := p.next()
+		if target.kind != tokIdent {
+			return result{}, fmt.Errorf("expected a unit after %q", t.text)
+		}
+		switch strings.ToLower(target.text) {
+		case "hex", "bin", "oct", "dec":
+			if v.unit != nil {
+				return result{}, fmt.Errorf("can not show %s in %s", v.unit.symbol, target.text)
+			}
+			if _, ok := v.integer(); !ok {
+				return result{}, fmt.Errorf("only integers can be shown in %s", target.text)
+			}
+			res.format = strings.ToLower(target.text)
+		default:
+			u, ok := lookupUnit(target.text)
+			if !ok {
+				return result{}, fmt.Errorf("unknown unit %q", target.text)
+			}
+			if res.value, err = v.convert(u); err != nil {
+				return result{}, err
+			}
+		}
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return result{}, unexpected(t)
+	}
+	return res, nil
+}
+
+func isConversion(word string) bool {
+	switch strings.ToLower(word) {
+	case "to", "in", "as":
+		return true
+	}
+	return false
+}
+
+func (p *interpreter) expr() (value, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return value{}, fmt.Errorf("expression is nested too deeply")
+	}
+	left, err := p.term()
+	if err != nil {
+		return value{}, err
+	}
+	for t := p.peek(); t.kind == tokOp && (t.text == "+" || t.text == "-"); t = p.peek() {
+		p.next()
+		right, err := p.term()
+		if err != nil {
+			return value{}, err
+		}
+		if left, err = add(left, right, t.text == "-"); err != nil {
+			return value{}, err
+		}
+	}
+	return left, nil
+}
+
+func (p *interpreter) term() (value, error) {
+	left, err := p.unary()
+	if err != nil {
+		return value{}, err
+	}
+	for t := p.peek(); t.kind == tokOp && (t.text == "*" || t.text == "/" || t.text == "%"); t = p.peek() {
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return value{}, err
+		}
+		if left, err = multiply(left, right, t.text); err != nil {
+			return value{}, err
+		}
+	}
+	return left, nil
+}
+
+func (p *interpreter) unary() (value, error) {
+	if t := p.peek(); t.kind == tokOp && (t.text == "-" || t.text == "+") {
+		p.next()
+		v, err := p.unary()
+		if err != nil || t.text == "+" {
+			return v, err
+		}
+		return negate(v)
+	}
+	return p.power()
+}
+
+func (p *interpreter) power() (value, error) {
+	base, err := p.postfix()
+	if err != nil {
+		return value{}, err
+	}
+	if t := p.peek(); t.kind == tokOp && t.text == "^" {
+		p.next()
+		exp, err := p.unary()
+		if err != nil {
+			return value{}, err
+		}
+		return pow(base, exp)
+	}
+	return base, nil
+}
+
+func (p *interpreter) postfix() (value, error) {
+	v, err := p.primary()
+	if err != nil {
+		return value{}, err
+	}
+	t := p.peek()
+	if t.kind != tokIdent || v.unit != nil {
+		return v, nil
+	}
+	u, ok := lookupUnit(t.text)
+	if !ok {
+		return v, nil
+	}
+	// "in" is inches unless it converts, as in "12 in in cm" or "5 ft in m".
+	if isConversion(t.text) {
+		if n := p.peekAt(1); n.kind == tokIdent && !isConversion(n.text) {
+			return v, nil
+		}
+	}
+	p.next()
+	v.unit = u
+	return v, nil
+}
+
+func (p *interpreter) primary() (value, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		if t.radix != 10 && p.radix == 0 {
+			p.radix = t.radix
+		}
+		return exactValue(t.num), nil
+	case tokOpen:
+		v, err := p.expr()
+		if err != nil {
+			return value{}, err
+		}
+		if c := p.next(); c.kind != tokClose {
+			return value{}, fmt.Errorf("expected ) to close ( at position %d, got %s", t.pos+1, c.describe())
+		}
+		return v, nil
+	case tokIdent:
+		if p.peek().kind == tokOpen {
+			return p.call(t)
+		}
+		if c, ok := constants[strings.ToLower(t.text)]; ok {
+			return floatValue(c)
+		}
+		if u, ok := lookupUnit(t.text); ok {
+			return value{exact: big.NewRat(1, 1), unit: u}, nil
+		}
+		return value{}, fmt.Errorf("unknown name %q at position %d", t.text, t.pos+1)
+	case tokEOF:
+		return value{}, fmt.Errorf("unexpected end of input, expected a number")
+	}
+	return value{}, unexpected(t)
+}
+
+func (p *interpreter) call(name token) (value, error) {
+	fn, ok := functions[strings.ToLower(name.text)]
+	if !ok {
+		return value{}, fmt.Errorf("unknown function %q at position %d", name.text, name.pos+1)
+	}
+	p.next() // (
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return value{}, fmt.Errorf("expression is nested too deeply")
+	}
+	var args []value
+	if p.peek().kind != tokClose {
+		for {
+			v, err := p.expr()
+			if err != nil {
+				return value{}, err
+			}
+			args = append(args, v)
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.next()
+		}
+	}
+	if c := p.next(); c.kind != tokClose {
+		return value{}, fmt.Errorf("expected ) to close %s( at position %d, got %s", name.text, name.pos+1, c.describe())
+	}
+	if len(args) < fn.min || (fn.max >= 0 && len(args) > fn.max) {
+		return value{}, fmt.Errorf("%s takes %s", name.text, fn.arity())
+	}
+	v, err := fn.eval(args)
+	if err != nil {
+		return value{}, fmt.Errorf("%s: %s", name.text, err)
+	}
+	return v, nil
+}
+
+// sameUnit converts b to the unit of a for adding or comparing them.
+func sameUnit(a, b value) (value, error) {
+	switch {
+	case a.unit == nil && b.unit == nil:
+		return b, nil
+	case a.unit == nil || b.unit == nil:
+		return value{}, fmt.Errorf("can not combine %s and %s, only one has a unit", a, b)
+	case a.unit.affine() || b.unit.affine():
+		return value{}, fmt.Errorf("temperatures in %s can only be converted", a.unit.symbol)
+	}
+	return b.convert(a.unit)
+}
+
+// exact reports whether combining a and b can be done exactly, within
+// maxBits.
+func exact(a, b value) bool {
+	return a.exact != nil && b.exact != nil && a.bits()+b.bits() <= maxBits
+}
+
+func add(a, b value, subtract bool) (value, error) {
+	b, err := sameUnit(a, b)
+	if err != nil {
+		return value{}, err
+	}
+	if exact(a, b) {
+		r := new(big.Rat)
+		if subtract {
+			r.Sub(a.exact, b.exact)
+		} else {
+			r.Add(a.exact, b.exact)
+		}
+		return value{exact: r, unit: a.unit}, nil
+	}
+	f := a.float() + b.float()
+	if subtract {
+		f = a.float() - b.float()
+	}
+	v, err := floatValue(f)
+	v.unit = a.unit
+	return v, err
+}
+
+func negate(v value) (value, error) {
+	if v.exact != nil {
+		v.exact = new(big.Rat).Neg(v.exact)
+		return v, nil
+	}
+	v.f = -v.f
+	return v, nil
+}
+
+func multiply(a, b value, op string) (value, error) {
+	if b.unit != nil && (a.unit != nil || op != "*") {
+		return value{}, fmt.Errorf("can not %s %s by %s, units can only be scaled by plain numbers", verbs[op], a, b)
+	}
+	u := a.unit
+	if u == nil {
+		u = b.unit
+	}
+	if u != nil && u.affine() {
+		return value{}, fmt.Errorf("temperatures in %s can only be converted", u.symbol)
+	}
+	if op != "*" && b.sign() == 0 {
+		return value{}, fmt.Errorf("division by zero")
+	}
+	if exact(a, b) {
+		r := new(big.Rat)
+		switch op {
+		case "*":
+			r.Mul(a.exact, b.exact)
+		case "/":
+			r.Quo(a.exact, b.exact)
+		case "%":
+			x, xok := a.integer()
+			y, yok := b.integer()
+			if !xok || !yok {
+				return floatMultiply(a, b, op, u)
+			}
+			r.SetInt(x.Rem(x, y))
+		}
+		return value{exact: r, unit: u}, nil
+	}
+	return floatMultiply(a, b, op, u)
+}
+
+var verbs = map[string]string{"*": "multiply", "/": "divide", "%": "take the remainder of"}
+
+func floatMultiply(a, b value, op string, u *unit) (value, error) {
+	var f float64
+	switch op {
+	case "*":
+		f = a.float() * b.float()
+	case "/":
+		f = a.float() / b.float()
+	case "%":
+		f = math.Mod(a.float(), b.float())
+	}
+	v, err := floatValue(f)
+	v.unit = u
+	return v, err
+}
+
+func pow(base, exp value) (value, error) {
+	if base.unit != nil || exp.unit != nil {
+		return value{}, fmt.Errorf("can not raise units to a power")
+	}
+	if n, ok := exp.integer(); ok && base.exact != nil && n.IsInt64() {
+		e := n.Int64()
+		if e < 0 && base.exact.Sign() == 0 {
+			return value{}, fmt.Errorf("division by zero")
+		}
+		abs := e
+		if abs < 0 {
+			abs = -abs
+		}
+		if bits := int64(base.bits()); abs <= maxBits && bits*abs <= maxBits {
+			num := new(big.Int).Exp(base.exact.Num(), big.NewInt(abs), nil)
+			den := new(big.Int).Exp(base.exact.Denom(), big.NewInt(abs), nil)
+			if e < 0 {
+				num, den = den, num
+			}
+			return exactValue(new(big.Rat).SetFrac(num, den)), nil
+		}
+	}
+	return floatValue(math.Pow(base.float(), exp.float()))
+}
@@ -0,0 +1,143 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// function is a builtin taking between min and max arguments, max -1 meaning
+// any number.
+type function struct {
+	min, max int
+	eval     func(args []value) (value, error)
+}
+
+func (f function) arity() string {
+	switch {
+	case f.max < 0 && f.min == 1:
+		return "at least 1 argument"
+	case f.max < 0:
+		return fmt.Sprintf("at least %d arguments", f.min)
+	case f.min == f.max && f.min == 1:
+		return "1 argument"
+	case f.min == f.max:
+		return fmt.Sprintf("%d arguments", f.min)
+	}
+	return fmt.Sprintf("%d to %d arguments", f.min, f.max)
+}
+
+var functions map[string]function
+
+func init() {
+	functions = map[string]function{
+		"sqrt":  {1, 1, sqrt},
+		"abs":   {1, 1, abs},
+		"ln":    {1, 1, unitless(math.Log, true)},
+		"log2":  {1, 1, unitless(math.Log2, true)},
+		"log10": {1, 1, unitless(math.Log10, true)},
+		"log":   {1, 2, logarithm},
+		"exp":   {1, 1, unitless(math.Exp, false)},
+		"sin":   {1, 1, unitless(math.Sin, false)},
+		"cos":   {1, 1, unitless(math.Cos, false)},
+		"tan":   {1, 1, unitless(math.Tan, false)},
+		"floor": {1, 1, rounding(math.Floor)},
+		"ceil":  {1, 1, rounding(math.Ceil)},
+		"round": {1, 1, rounding(math.Round)},
+		"min":   {1, -1, extreme(-1)},
+		"max":   {1, -1, extreme(1)},
+	}
+}
+
+// unitless adapts fn to plain numbers. When positive is set the argument
+// must be above zero.
+func unitless(fn func(float64) float64, positive bool) func([]value) (value, error) {
+	return func(args []value) (value, error) {
+		if args[0].unit != nil {
+			return value{}, fmt.Errorf("expected a plain number, got %s", args[0])
+		}
+		if positive && args[0].sign() <= 0 {
+			return value{}, fmt.Errorf("expected a number above 0, got %s", args[0])
+		}
+		return floatValue(fn(args[0].float()))
+	}
+}
+
+// logarithm is log(x), the natural logarithm, or log(x, base).
+func logarithm(args []value) (value, error) {
+	x, err := unitless(math.Log, true)(args[:1])
+	if err != nil || len(args) == 1 {
+		return x, err
+	}
+	base, err := unitless(math.Log, true)(args[1:])
+	if err != nil {
+		return value{}, err
+	}
+	if base.f == 0 {
+		return value{}, fmt.Errorf("base 1 has no logarithm")
+	}
+	return floatValue(x.f / base.f)
+}
+
+func sqrt(args []value) (value, error) {
+	v := args[0]
+	if v.unit != nil {
+		return value{}, fmt.Errorf("expected a plain number, got %s", v)
+	}
+	if v.sign() < 0 {
+		return value{}, fmt.Errorf("expected a number of at least 0, got %s", v)
+	}
+	// Perfect squares stay exact, e.g. sqrt(2^200).
+	if v.exact != nil {
+		num, den := new(big.Int).Sqrt(v.exact.Num()), new(big.Int).Sqrt(v.exact.Denom())
+		if new(big.Int).Mul(num, num).Cmp(v.exact.Num()) == 0 && new(big.Int).Mul(den, den).Cmp(v.exact.Denom()) == 0 {
+			return exactValue(new(big.Rat).SetFrac(num, den)), nil
+		}
+	}
+	return floatValue(math.Sqrt(v.float()))
+}
+
+func abs(args []value) (value, error) {
+	if args[0].sign() < 0 {
+		return negate(args[0])
+	}
+	return args[0], nil
+}
+
+func rounding(fn func(float64) float64) func([]value) (value, error) {
+	return func(args []value) (value, error) {
+		v := args[0]
+		if v.exact != nil {
+			if v.exact.IsInt() {
+				return v, nil
+			}
+			// Round the fraction exactly, its integer part may be too large
+			// for a float.
+			whole := new(big.Int).Quo(v.exact.Num(), v.exact.Denom())
+			frac := new(big.Rat).Sub(v.exact, new(big.Rat).SetInt(whole))
+			f, _ := frac.Float64()
+			whole.Add(whole, big.NewInt(int64(fn(f))))
+			return value{exact: new(big.Rat).SetInt(whole), unit: v.unit}, nil
+		}
+		r, err := floatValue(fn(v.f))
+		r.unit = v.unit
+		return r, err
+	}
+}
+
+// extreme returns min for -1 and max for 1.
+func extreme(want int) func([]value) (value, error) {
+	return func(args []value) (value, error) {
+		best := args[0]
+		for _, v := range args[1:] {
+			v, err := sameUnit(best, v)
+			if err != nil {
+				return value{}, err
+			}
+			if v.rat().Cmp(best.rat()) == want {
+				best = v
+			}
+		}
+		return best, nil
+	}
+}
@@ -0,0 +1,119 @@
+package calc
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokOp
+	tokOpen
+	tokClose
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	// num is the value of a tokNumber.
+	num *big.Rat
+	// radix is the base a tokNumber was written in.
+	radix int
+}
+
+func (t token) describe() string {
+	if t.kind == tokEOF {
+		return "end of input"
+	}
+	return fmt.Sprintf("%q at position %d", t.text, t.pos+1)
+}
+
+var (
+	radixPattern   = regexp.MustCompile(`^0([xXbBoO])([0-9a-fA-F_]+)`)
+	decimalPattern = regexp.MustCompile(`^(\d[\d_]*\.?[\d_]*|\.\d[\d_]*)([eE][+-]?\d+)?`)
+)
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+	for pos := 0; pos < len(s); {
+		r, size := utf8.DecodeRuneInString(s[pos:])
+		rest := s[pos:]
+		switch {
+		case unicode.IsSpace(r):
+			pos += size
+		case strings.HasPrefix(rest, "**"):
+			tokens = append(tokens, token{kind: tokOp, text: "^", pos: pos})
+			pos += 2
+		case strings.ContainsRune("+-*/%^", r):
+			tokens = append(tokens, token{kind: tokOp, text: string(r), pos: pos})
+			pos += size
+		case r == '×' || r == '÷':
+			op := "*"
+			if r == '÷' {
+				op = "/"
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: pos})
+			pos += size
+		case r == '(':
+			tokens = append(tokens, token{kind: tokOpen, text: "(", pos: pos})
+			pos += size
+		case r == ')':
+			tokens = append(tokens, token{kind: tokClose, text: ")", pos: pos})
+			pos += size
+		case r == ',':
+			tokens = append(tokens, token{kind: tokComma, text: ",", pos: pos})
+			pos += size
+		case radixPattern.MatchString(rest):
+			m := radixPattern.FindStringSubmatch(rest)
+			radix := map[byte]int{'x': 16, 'b': 2, 'o': 8}[strings.ToLower(m[1])[0]]
+			n, ok := new(big.Int).SetString(strings.Replace(m[2], "_", "", -1), radix)
+			if !ok {
+				return nil, fmt.Errorf("invalid number %q at position %d", m[0], pos+1)
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: m[0], pos: pos, num: new(big.Rat).SetInt(n), radix: radix})
+			pos += len(m[0])
+		case decimalPattern.MatchString(rest):
+			m := decimalPattern.FindStringSubmatch(rest)
+			text := m[0]
+			if len(m[2]) > 0 {
+				if exp, err := strconv.Atoi(strings.TrimLeft(m[2][1:], "+")); err != nil || exp > maxExponent || exp < -maxExponent {
+					return nil, fmt.Errorf("exponent of %q at position %d is out of range", text, pos+1)
+				}
+			}
+			n, ok := new(big.Rat).SetString(strings.Replace(text, "_", "", -1))
+			if !ok {
+				return nil, fmt.Errorf("invalid number %q at position %d", text, pos+1)
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: text, pos: pos, num: n, radix: 10})
+			pos += len(text)
+		case unicode.IsLetter(r) || r == '_' || r == '°' || r == 'µ':
+			end := pos + size
+			for end < len(s) {
+				r, size := utf8.DecodeRuneInString(s[end:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+					break
+				}
+				end += size
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: s[pos:end], pos: pos})
+			pos = end
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", r, pos+1)
+		}
+		if len(tokens) > maxTokens {
+			return nil, fmt.Errorf("expression is too long")
+		}
+	}
+	return tokens, nil
+}
@@ -0,0 +1,135 @@
+package calc
+
+import (
+	"math/big"
+	"strings"
+)
+
+// unit is a unit of measure. A value v in the unit is v*factor+offset in the
+// base unit of its dimension. Only temperatures have an offset.
+type unit struct {
+	symbol    string
+	dimension string
+	factor    *big.Rat
+	offset    *big.Rat
+}
+
+// affine reports whether the unit has an offset, which rules out arithmetic
+// other than conversion.
+func (u *unit) affine() bool {
+	return u.offset.Sign() != 0
+}
+
+// units are looked up by exact name first, then by lower case name unless
+// that is ambiguous, e.g. "mb" could be megabytes or megabits.
+var (
+	units      = map[string]*unit{}
+	unitsLower = map[string]*unit{}
+)
+
+func rat(s string) *big.Rat {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		panic("invalid rational " + s)
+	}
+	return r
+}
+
+// define adds a unit known by symbol and names.
+func define(dimension, factor, offset, symbol string, names ...string) {
+	u := &unit{
+		symbol:    symbol,
+		dimension: dimension,
+		factor:    rat(factor),
+		offset:    rat(offset),
+	}
+	for _, n := range append([]string{symbol}, names...) {
+		if _, dup := units[n]; dup {
+			panic("duplicate unit " + n)
+		}
+		units[n] = u
+	}
+}
+
+func init() {
+	define("length", "1", "0", "m", "meter", "meters", "metre", "metres")
+	define("length", "1000", "0", "km", "kilometer", "kilometers", "kilometre", "kilometres")
+	define("length", "1/100", "0", "cm", "centimeter", "centimeters", "centimetre", "centimetres")
+	define("length", "1/1000", "0", "mm", "millimeter", "millimeters", "millimetre", "millimetres")
+	define("length", "1/1000000", "0", "um", "µm", "micrometer", "micrometers")
+	define("length", "1/1000000000", "0", "nm", "nanometer", "nanometers")
+	define("length", "1609.344", "0", "mi", "mile", "miles")
+	define("length", "0.9144", "0", "yd", "yard", "yards")
+	define("length", "0.3048", "0", "ft", "foot", "feet")
+	define("length", "0.0254", "0", "in", "inch", "inches")
+	define("length", "1852", "0", "nmi", "nauticalmile", "nauticalmiles")
+
+	define("mass", "1", "0", "g", "gram", "grams")
+	define("mass", "1000", "0", "kg", "kilogram", "kilograms", "kilo", "kilos")
+	define("mass", "1/1000", "0", "mg", "milligram", "milligrams")
+	define("mass", "1000000", "0", "t", "tonne", "tonnes")
+	define("mass", "453.59237", "0", "lb", "lbs", "pound", "pounds")
+	define("mass", "28.349523125", "0", "oz", "ounce", "ounces")
+	define("mass", "6350.29318", "0", "st", "stone", "stones")
+
+	define("time", "1", "0", "s", "sec", "secs", "second", "seconds")
+	define("time", "1/1000", "0", "ms", "millisecond", "milliseconds")
+	define("time", "1/1000000", "0", "us", "µs", "microsecond", "microseconds")
+	define("time", "1/1000000000", "0", "ns", "nanosecond", "nanoseconds")
+	define("time", "60", "0", "min", "mins", "minute", "minutes")
+	define("time", "3600", "0", "h", "hr", "hrs", "hour", "hours")
+	define("time", "86400", "0", "d", "day", "days")
+	define("time", "604800", "0", "wk", "week", "weeks")
+	define("time", "31557600", "0", "yr", "year", "years")
+
+	define("data", "1", "0", "bit", "bits", "b")
+	define("data", "1000", "0", "kbit", "kb")
+	define("data", "1000000", "0", "Mbit", "Mb")
+	define("data", "1000000000", "0", "Gbit", "Gb")
+	define("data", "1000000000000", "0", "Tbit", "Tb")
+	define("data", "8", "0", "B", "byte", "bytes")
+	define("data", "8000", "0", "kB", "KB", "kilobyte", "kilobytes")
+	define("data", "8000000", "0", "MB", "megabyte", "megabytes")
+	define("data", "8000000000", "0", "GB", "gigabyte", "gigabytes")
+	define("data", "8000000000000", "0", "TB", "terabyte", "terabytes")
+	define("data", "8000000000000000", "0", "PB", "petabyte", "petabytes")
+	define("data", "8192", "0", "KiB", "kibibyte", "kibibytes")
+	define("data", "8388608", "0", "MiB", "mebibyte", "mebibytes")
+	define("data", "8589934592", "0", "GiB", "gibibyte", "gibibytes")
+	define("data", "8796093022208", "0", "TiB", "tebibyte", "tebibytes")
+	define("data", "9007199254740992", "0", "PiB", "pebibyte", "pebibytes")
+
+	define("volume", "1", "0", "L", "l", "liter", "liters", "litre", "litres")
+	define("volume", "1/1000", "0", "mL", "ml", "milliliter", "milliliters", "millilitre", "millilitres")
+	define("volume", "1000", "0", "m3", "cubicmeter", "cubicmeters")
+	define("volume", "3.785411784", "0", "gal", "gallon", "gallons")
+	define("volume", "0.946352946", "0", "qt", "quart", "quarts")
+	define("volume", "0.473176473", "0", "pt", "pint", "pints")
+	define("volume", "0.2365882365", "0", "cup", "cups")
+	define("volume", "0.0295735295625", "0", "floz")
+
+	define("temperature", "1", "0", "K", "kelvin")
+	define("temperature", "1", "273.15", "°C", "C", "degC", "celsius")
+	define("temperature", "5/9", "45967/180", "°F", "F", "degF", "fahrenheit")
+
+	ambiguous := map[string]bool{}
+	for name, u := range units {
+		lower := strings.ToLower(name)
+		if other, ok := unitsLower[lower]; ok && other != u {
+			ambiguous[lower] = true
+		}
+		unitsLower[lower] = u
+	}
+	for lower := range ambiguous {
+		delete(unitsLower, lower)
+	}
+}
+
+// lookupUnit finds the unit called name.
+func lookupUnit(name string) (*unit, bool) {
+	if u, ok := units[name]; ok {
+		return u, true
+	}
+	u, ok := unitsLower[strings.ToLower(name)]
+	return u, ok
+}
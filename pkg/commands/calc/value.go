@@ -0,0 +1,141 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// value is a number, exact while only exact operations were applied to it,
+// optionally in a unit.
+type value struct {
+	// exact is the value when known exactly, otherwise nil and f holds it.
+	exact *big.Rat
+	f     float64
+	unit  *unit
+}
+
+func exactValue(r *big.Rat) value {
+	return value{exact: r}
+}
+
+func floatValue(f float64) (value, error) {
+	if math.IsNaN(f) {
+		return value{}, fmt.Errorf("result is not a number")
+	}
+	if math.IsInf(f, 0) {
+		return value{}, fmt.Errorf("result is too large")
+	}
+	return value{f: f}, nil
+}
+
+func (v value) float() float64 {
+	if v.exact != nil {
+		f, _ := v.exact.Float64()
+		return f
+	}
+	return v.f
+}
+
+// rat returns v as a rational, exact or not.
+func (v value) rat() *big.Rat {
+	if v.exact != nil {
+		return v.exact
+	}
+	return new(big.Rat).SetFloat64(v.f)
+}
+
+func (v value) sign() int {
+	if v.exact != nil {
+		return v.exact.Sign()
+	}
+	switch {
+	case v.f < 0:
+		return -1
+	case v.f > 0:
+		return 1
+	}
+	return 0
+}
+
+// bits is the size of the numerator and denominator of an exact v.
+func (v value) bits() int {
+	if v.exact == nil {
+		return 0
+	}
+	return v.exact.Num().BitLen() + v.exact.Denom().BitLen()
+}
+
+// integer returns v as an integer, if it is one.
+func (v value) integer() (*big.Int, bool) {
+	if v.exact != nil && v.exact.IsInt() {
+		return new(big.Int).Set(v.exact.Num()), true
+	}
+	return nil, false
+}
+
+// convert returns v in unit to.
+func (v value) convert(to *unit) (value, error) {
+	if v.unit == nil {
+		return value{}, fmt.Errorf("%s has no unit to convert to %s", v, to.symbol)
+	}
+	if v.unit.dimension != to.dimension {
+		return value{}, fmt.Errorf("can not convert %s (%s) to %s (%s)", v.unit.symbol, v.unit.dimension, to.symbol, to.dimension)
+	}
+	if v.unit == to {
+		return v, nil
+	}
+	// base = v*from.factor+from.offset, result = (base-to.offset)/to.factor
+	r := new(big.Rat).Mul(v.rat(), v.unit.factor)
+	r.Add(r, v.unit.offset)
+	r.Sub(r, to.offset)
+	r.Quo(r, to.factor)
+	if v.exact != nil {
+		return value{exact: r, unit: to}, nil
+	}
+	f, _ := r.Float64()
+	return value{f: f, unit: to}, nil
+}
+
+// String formats v, exact integers in full unless they are huge, other
+// values with up to 12 significant digits.
+func (v value) String() string {
+	s := v.number()
+	if v.unit != nil {
+		s += " " + v.unit.symbol
+	}
+	return s
+}
+
+func (v value) number() string {
+	if n, ok := v.integer(); ok {
+		// An n of b bits has at least (b-1)*log10(2)+1 digits, so huge
+		// numbers are not converted to decimal only to be discarded.
+		if float64(n.BitLen()-1)*math.Log10(2) < maxDigits {
+			if s := n.String(); len(s) <= maxDigits {
+				return s
+			}
+		}
+		f := new(big.Float).SetInt(n)
+		return f.Text('g', 12)
+	}
+	if v.exact != nil {
+		f := new(big.Float).SetPrec(256).SetRat(v.exact)
+		return trimFloat(f.Text('g', 12))
+	}
+	return trimFloat(strconv.FormatFloat(v.f, 'g', 12, 64))
+}
+
+// trimFloat removes trailing zeros of the fraction, keeping an exponent.
+func trimFloat(s string) string {
+	mantissa, exponent := s, ""
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mantissa, exponent = s[:i], s[i:]
+	}
+	if strings.Contains(mantissa, ".") {
+		mantissa = strings.TrimRight(strings.TrimRight(mantissa, "0"), ".")
+	}
+	return mantissa + exponent
+}
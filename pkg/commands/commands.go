@@ -3,122 +3,386 @@ package commands
 import (
 	"context"
 	"fmt"
-	"github.com/botless/events/pkg/events"
+	"github.com/botless/commands/pkg/commands/args"
+	"github.com/botless/commands/pkg/dedupe"
+	"github.com/botless/commands/pkg/logging"
+	"github.com/botless/commands/pkg/store"
+	"github.com/botless/commands/pkg/tracing"
 	"github.com/cloudevents/sdk-go/pkg/cloudevents"
 	"github.com/cloudevents/sdk-go/pkg/cloudevents/client"
-	"github.com/cloudevents/sdk-go/pkg/cloudevents/types"
-	"log"
-	"net/url"
+	"net/http"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Commands struct {
-	Ce         client.Client
-	StrictType string
+	Ce client.Client
+
+	// Filter selects the event types to handle. Defaults to all types.
+	Filter *TypeFilter
+
+	// Sender delivers responses. Defaults to sending once with Ce.
+	Sender *Sender
+
+	// Metrics records what is handled. Optional.
+	Metrics *Metrics
+
+	// Dedupe drops events whose source and ID were already handled within
+	// DedupeTTL. Optional.
+	Dedupe    dedupe.Store
+	DedupeTTL time.Duration
+
+	// Aliases holds the user defined aliases resolved before dispatch.
+	// Optional.
+	Aliases AliasStore
+
+	// Store persists the state of stateful commands, available to handlers
+	// as Request.Store. Optional.
+	Store store.Store
+
+	// Pipelines splits the arguments of a command at each unquoted | and
+	// feeds the output of each stage into the arguments of the next, e.g.
+	// "echo hi | caps | flip". All stages must be handled here. When a stage
+	// does not name a command the arguments are passed on as typed, so
+	// "echo a|b" still echoes "a|b".
+	Pipelines bool
+
+	// Suggest replies to unknown commands with the closest registered
+	// commands selected by Filter. Otherwise unknown commands are ignored.
+	Suggest bool
+
+	// ACL decides who may run which command where. Without an ACL every
+	// command that is not AdminOnly is allowed.
+	ACL Authorizer
+
+	// RateLimits are the default limits for commands that do not declare
+	// their own.
+	RateLimits RateLimits
+	// RateLimitReply sends a "slow down" reply once per window when a
+	// command is rate limited. Otherwise limited commands are dropped
+	// silently.
+	RateLimitReply bool
+
+	// Logger is used for all logging, with fields identifying the event
+	// added. Defaults to logging.Default().
+	Logger *logging.Logger
+
+	// Tracer records spans for handled events. Optional.
+	Tracer *tracing.Tracer
+
+	// Registry is used to dispatch events. Defaults to DefaultRegistry.
+	Registry *Registry
+
+	// Workers is the number of events handled concurrently by Receive.
+	// Defaults to 16.
+	Workers int
+	// QueueSize is the number of events Receive will accept while all workers
+	// are busy. Once full, events are rejected with a retryable status.
+	// Defaults to 100.
+	QueueSize int
+	// Synchronous makes Receive handle the event before returning, reporting
+	// send failures in the response so the sender can retry.
+	Synchronous bool
+
+	limiters limiters
+
+	poolOnce sync.Once
+	pool     *pool
+
+	mu       sync.Mutex
+	draining bool
+	inflight sync.WaitGroup
 }
 
-func (c *Commands) Receive(event cloudevents.Event) {
-	// don't block the caller.
-	go c.receive(event)
+// track registers an event as in flight, returning false once draining.
+func (c *Commands) track() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.draining {
+		return false
+	}
+	c.inflight.Add(1)
+	return true
+}
+
+// Drain stops accepting new events and waits until all accepted events have
+// been handled, or ctx is done.
+func (c *Commands) Drain(ctx context.Context) error {
+	c.mu.Lock()
+	c.draining = true
+	c.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("commands still in flight: %s", ctx.Err())
+	}
 }
 
-func (c *Commands) receive(event cloudevents.Event) {
-	if c.StrictType != "" && event.Type() != c.StrictType {
+// Receive handles event and sends any replies with Ce. Unless Synchronous is
+// set the event is queued to be handled in the background.
+func (c *Commands) Receive(ctx context.Context, event cloudevents.Event, resp *cloudevents.EventResponse) {
+	if !c.track() {
+		resp.Error(http.StatusServiceUnavailable, "shutting down")
 		return
 	}
-	switch event.Type() {
-	case "botless.bot.command.echo":
-		c.Echo(event)
-	case "botless.bot.command.caps":
-		c.Caps(event)
-	case "botless.bot.command.flip":
-		c.Flip(event)
-	default:
-		// ignore
-		log.Printf("botless command ignored event type %q", event.Type())
+	if c.Synchronous {
+		defer c.inflight.Done()
+		var sent *sendResponder
+		c.receive(ctx, event, func(ctx context.Context, name string, req *Request) Responder {
+			sent = c.newSendResponder(ctx, name, req)
+			return sent
+		})
+		if sent != nil && sent.err != nil {
+			// Let the redelivery through.
+			c.forget(ctx, event)
+			resp.Error(http.StatusBadGateway, sent.err.Error())
+		}
+		return
+	}
+	if !c.startPool().offer(event) {
+		c.inflight.Done()
+		c.eventLogger(event).Warnf("queue full, rejecting event")
+		resp.Error(http.StatusServiceUnavailable, "queue full")
 	}
 }
 
-func (c *Commands) Echo(parent cloudevents.Event) {
-	if parent.Type() != "botless.bot.command.echo" {
+// ReceiveAndReply handles event before returning and sets the reply as the
+// response event, so no target is needed to deliver it.
+func (c *Commands) ReceiveAndReply(ctx context.Context, event cloudevents.Event, resp *cloudevents.EventResponse) {
+	if !c.track() {
+		resp.Error(http.StatusServiceUnavailable, "shutting down")
 		return
 	}
-	cmd := &events.Command{}
-	if err := parent.DataAs(cmd); err != nil {
-		log.Printf("failed to get events.Command from %s", parent.Type())
+	defer c.inflight.Done()
+	c.receive(ctx, event, func(ctx context.Context, name string, req *Request) Responder {
+		return &replyResponder{
+			ctx:     ctx,
+			metrics: c.Metrics,
+			tracer:  c.Tracer,
+			name:    name,
+			req:     req,
+			resp:    resp,
+		}
+	})
+}
+
+func (c *Commands) newSendResponder(ctx context.Context, name string, req *Request) *sendResponder {
+	sender := c.Sender
+	if sender == nil {
+		sender = &Sender{Ce: c.Ce}
+	}
+	return &sendResponder{
+		ctx:     ctx,
+		sender:  sender,
+		metrics: c.Metrics,
+		tracer:  c.Tracer,
+		name:    name,
+		req:     req,
+	}
+}
+
+const defaultDedupeTTL = 10 * time.Minute
+
+func dedupeKey(event cloudevents.Event) string {
+	ec := event.Context.AsV02()
+	return ec.Source.String() + "\x00" + ec.ID
+}
+
+// duplicate marks event as seen and reports whether it was seen before.
+// Events are not deduped when the store fails.
+func (c *Commands) duplicate(ctx context.Context, event cloudevents.Event) bool {
+	if c.Dedupe == nil {
+		return false
+	}
+	ttl := c.DedupeTTL
+	if ttl <= 0 {
+		ttl = defaultDedupeTTL
+	}
+	seen, err := c.Dedupe.Seen(ctx, dedupeKey(event), ttl)
+	if err != nil {
+		c.eventLogger(event).Errorf("failed to check for duplicate event: %s", err)
+		return false
+	}
+	return seen
+}
+
+// forget lets a redelivery of event be handled again.
+func (c *Commands) forget(ctx context.Context, event cloudevents.Event) {
+	if c.Dedupe == nil {
 		return
 	}
-	ec := parent.Context.AsV02()
-	event := cloudevents.Event{
-		Context: cloudevents.EventContextV02{
-			Type:       events.Bot.Type("response"),
-			Source:     *types.ParseURLRef("//botless/command/echo"),
-			Extensions: ec.Extensions,
-		}.AsV02(),
-		Data: events.Message{
-			Channel: cmd.Channel,
-			Text:    cmd.Args,
-		},
+	if err := c.Dedupe.Forget(ctx, dedupeKey(event)); err != nil {
+		c.eventLogger(event).Errorf("failed to forget event: %s", err)
 	}
-	if _, err := c.Ce.Send(context.TODO(), event); err != nil {
-		log.Printf("failed to send cloudevent: %s\n", err)
-	} else {
-		log.Printf("echo sent %s", cmd.Args)
+}
+
+// Authorizer decides whether an author may run a command in a channel.
+type Authorizer interface {
+	Allowed(author, channel, command string, adminOnly bool) bool
+}
+
+func (c *Commands) allowed(cmd *Command, req *Request) bool {
+	if c.ACL == nil {
+		return !cmd.AdminOnly
+	}
+	return c.ACL.Allowed(req.Author, req.Channel, cmd.Name, cmd.AdminOnly)
+}
+
+func (c *Commands) logger() *logging.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return logging.Default()
+}
+
+// eventLogger returns a logger carrying the identity of event.
+func (c *Commands) eventLogger(event cloudevents.Event) *logging.Logger {
+	ec := event.Context.AsV02()
+	return c.logger().With(
+		"event_id", ec.ID,
+		"event_type", ec.Type,
+		"event_source", ec.Source.String(),
+	)
+}
+
+func (c *Commands) registry() *Registry {
+	if c.Registry != nil {
+		return c.Registry
 	}
+	return DefaultRegistry
 }
 
-func (c *Commands) Caps(parent cloudevents.Event) {
-	if parent.Type() != "botless.bot.command.caps" {
+// responderFunc creates the Responder for a request to the named command.
+type responderFunc func(ctx context.Context, name string, req *Request) Responder
+
+func (c *Commands) receive(ctx context.Context, event cloudevents.Event, responder responderFunc) {
+	logger := c.eventLogger(event)
+	cmd, ok := c.registry().LookupType(event.Type())
+	// Metrics are labelled with registered types only, so senders can not
+	// create unbounded series.
+	label := otherType
+	if ok {
+		label = event.Type()
+	}
+	c.Metrics.receivedEvent(label)
+	var alias *Alias
+	if !ok {
+		cmd, alias, ok = c.resolveAlias(ctx, event)
+	}
+	if !ok && c.Suggest {
+		c.Metrics.ignoredEvent(label)
+		logger.Infof("ignored unknown event type")
+		c.suggest(ctx, event, responder)
 		return
 	}
-	cmd := &events.Command{}
-	if err := parent.DataAs(cmd); err != nil {
-		log.Printf("failed to get events.Command from %s", parent.Type())
+	// Static and user defined aliases are selected by the type of the command
+	// they run, so only the service handling that command answers.
+	selected := event.Type()
+	if ok {
+		selected = cmd.Type
+	}
+	if !c.Filter.Match(selected) {
+		c.Metrics.ignoredEvent(label)
+		logger.Debugf("event type not selected by filter %q", c.Filter)
 		return
 	}
-	ec := parent.Context.AsV02()
-	event := cloudevents.Event{
-		Context: cloudevents.EventContextV02{
-			Type:       events.Bot.Type("response"),
-			Source:     *types.ParseURLRef("//botless/command/caps"),
-			Extensions: ec.Extensions,
-		}.AsV02(),
-		Data: events.Message{
-			Channel: cmd.Channel,
-			Text:    strings.ToUpper(cmd.Args),
-		},
+	if !ok {
+		// ignore
+		c.Metrics.ignoredEvent(label)
+		logger.Infof("ignored unknown event type")
+		return
 	}
-	if _, err := c.Ce.Send(context.TODO(), event); err != nil {
-		log.Printf("failed to send cloudevent: %s\n", err)
-	} else {
-		log.Printf("upper sent %s", cmd.Args)
+	logger = logger.With("command", cmd.Name)
+	if !c.registry().Enabled(cmd) {
+		c.Metrics.ignoredEvent(label)
+		logger.Infof("ignored event for disabled command")
+		return
 	}
-}
 
-func (c *Commands) Flip(parent cloudevents.Event) {
-	if parent.Type() != "botless.bot.command.flip" {
+	if c.duplicate(ctx, event) {
+		c.Metrics.duplicateEvent(label)
+		logger.Infof("dropped duplicate event")
 		return
 	}
-	cmd := &events.Command{}
-	if err := parent.DataAs(cmd); err != nil {
-		log.Printf("failed to get events.Command from %s", parent.Type())
+
+	ctx, span := c.Tracer.StartRemote(ctx, event.Type(), tracing.SpanKindServer, traceparent(event))
+	defer span.Finish()
+	ec := event.Context.AsV02()
+	span.SetAttribute("cloudevents.event_id", ec.ID)
+	span.SetAttribute("cloudevents.event_source", ec.Source.String())
+	span.SetAttribute("cloudevents.event_type", ec.Type)
+	span.SetAttribute("botless.command", cmd.Name)
+
+	req := &Request{Event: event, Store: c.Store}
+	if err := event.DataAs(&req.Command); err != nil {
+		c.Metrics.decodeFailure(label)
+		span.SetError(err)
+		logger.Errorf("failed to decode events.Command: %s", err)
 		return
 	}
-	ec := parent.Context.AsV02()
-	event := cloudevents.Event{
-		Context: cloudevents.EventContextV02{
-			Type:       events.Bot.Type("response"),
-			Source:     *types.ParseURLRef("//botless/command/flip"),
-			Extensions: ec.Extensions,
-		}.AsV02(),
-		Data: events.Message{
-			Channel: cmd.Channel,
-			Text:    fmt.Sprintf("https://tableflip.dev/?flip=%s", url.QueryEscape(cmd.Args)),
-		},
-	}
-	if _, err := c.Ce.Send(context.TODO(), event); err != nil {
-		log.Printf("failed to send cloudevent: %s\n", err)
-	} else {
-		log.Printf("flip sent %s", cmd.Args)
+	if alias != nil {
+		req.Cmd = cmd.Name
+		req.Args = strings.TrimSpace(alias.Args + " " + req.Args)
+		logger = logger.With("alias", alias.Name)
+	}
+	logger = logger.With("channel", req.Channel, "author", req.Author)
+	ctx = logging.WithLogger(ctx, logger)
+	logger.Debugf("handling %q", req.Args)
+	defer c.Metrics.handling(cmd.Name)()
+	resp := responder(ctx, cmd.Name, req)
+	if c.Pipelines {
+		// Arguments that do not form a pipeline, such as "a |", are passed
+		// to the command as typed.
+		stages, err := args.SplitPipeline(req.Args)
+		switch {
+		case err == nil && len(stages) > 1:
+			c.runPipeline(ctx, cmd, req, stages, resp)
+			return
+		case err == nil:
+			// A single stage only differs by having \| unescaped.
+			req.Args = stages[0]
+		}
+	}
+	c.run(ctx, cmd, req, resp)
+}
+
+// run checks the ACL, rate limits and arguments of req before handing it to
+// cmd, reporting whether the handler was called. When it was not the reason
+// has been sent with resp, unless the request was dropped silently.
+func (c *Commands) run(ctx context.Context, cmd *Command, req *Request, resp Responder) bool {
+	logger := logging.FromContext(ctx)
+	if !c.allowed(cmd, req) {
+		c.Metrics.deniedCommand(cmd.Name)
+		logger.Infof("denied by acl")
+		resp.Reply(fmt.Sprintf("you are not allowed to run `%s`", cmd.Name))
+		return false
+	}
+	if denied, notice := c.rateLimit(cmd, req); denied {
+		c.Metrics.rateLimited(cmd.Name)
+		logger.Infof("rate limited")
+		if notice != "" {
+			resp.Reply(notice)
+		}
+		return false
+	}
+	if cmd.Args != nil {
+		params, err := cmd.Args.Parse(req.Args)
+		if err != nil {
+			resp.ReplyError(fmt.Errorf("%s\nUsage: `%s`", err, cmd.Usage))
+			return false
+		}
+		req.Params = params
 	}
+	hctx, hspan := c.Tracer.Start(ctx, "handle "+cmd.Name, tracing.SpanKindInternal)
+	cmd.Handler(hctx, req, resp)
+	hspan.Finish()
+	return true
 }
@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/botless/commands/pkg/logging"
+	"github.com/botless/events/pkg/events"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents/types"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Commands log every event, only errors are worth reading here.
+	logging.SetDefault(logging.New(os.Stderr, logging.Error, false))
+	os.Exit(m.Run())
+}
+
+var eventID int64
+
+// commandEvent returns the event carrying cmd, with a type for cmd.Cmd.
+func commandEvent(t *testing.T, cmd events.Command) cloudevents.Event {
+	t.Helper()
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		t.Fatalf("Marshal: %s", err)
+	}
+	return cloudevents.Event{
+		Context: cloudevents.EventContextV02{
+			ID:          strconv.FormatInt(atomic.AddInt64(&eventID, 1), 10),
+			Type:        CommandType(cmd.Cmd),
+			Source:      *types.ParseURLRef("//test"),
+			ContentType: cloudevents.StringOfApplicationJSON(),
+		}.AsV02(),
+		Data: data,
+	}
+}
+
+// reply runs the command text, e.g. "echo hi", in channel and returns the
+// reply, or "" when there was none.
+func reply(t *testing.T, c *Commands, channel, text string) string {
+	t.Helper()
+	name, args := cutWord(text)
+	event := commandEvent(t, events.Command{Channel: channel, Author: "alice", Cmd: name, Args: args})
+	resp := &cloudevents.EventResponse{}
+	c.ReceiveAndReply(context.Background(), event, resp)
+	if resp.Event == nil {
+		return ""
+	}
+	msg, ok := resp.Event.Data.(events.Message)
+	if !ok {
+		t.Fatalf("reply data is %T, want events.Message", resp.Event.Data)
+	}
+	return msg.Text
+}
+
+// testRegistry holds echo and caps, with the alias shout.
+func testRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r := NewRegistry()
+	for _, cmd := range []Command{{
+		Name: "echo",
+		Handler: Text(func(args string) (string, error) {
+			return args, nil
+		}),
+	}, {
+		Name:    "caps",
+		Aliases: []string{"shout"},
+		Handler: Text(func(args string) (string, error) {
+			return strings.ToUpper(args), nil
+		}),
+	}} {
+		if err := r.Register(cmd); err != nil {
+			t.Fatalf("Register: %s", err)
+		}
+	}
+	return r
+}
+
+func mustFilter(t *testing.T, s string) *TypeFilter {
+	t.Helper()
+	f, err := ParseTypeFilter(s)
+	if err != nil {
+		t.Fatalf("ParseTypeFilter(%q): %s", s, err)
+	}
+	return f
+}
@@ -0,0 +1,155 @@
+// Package commandstest holds fakes for testing commands: a Recorder to run
+// handlers with, a Client to deliver their messages to and a Clock to
+// replace the time they see.
+package commandstest
+
+import (
+	"context"
+	"errors"
+	"github.com/botless/commands/pkg/commands"
+	"github.com/botless/commands/pkg/store"
+	"github.com/botless/events/pkg/events"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents/client"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recorder is a Responder keeping the replies. Errors are kept prefixed with
+// "error: ".
+type Recorder struct {
+	mu      sync.Mutex
+	replies []string
+}
+
+var _ commands.Responder = (*Recorder)(nil)
+
+func (r *Recorder) Reply(text string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replies = append(r.replies, text)
+}
+
+func (r *Recorder) ReplyMessage(msg events.Message) {
+	r.Reply(msg.Text)
+}
+
+func (r *Recorder) ReplyError(err error) {
+	r.Reply("error: " + err.Error())
+}
+
+// Replies returns the replies so far.
+func (r *Recorder) Replies() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.replies...)
+}
+
+// Run runs cmd as author in the channel "general" with args, parsing them
+// first when cmd declares Args as Commands does. It returns the replies
+// joined by newlines.
+func Run(s store.Store, cmd commands.Command, author, args string) string {
+	req := &commands.Request{
+		Command: events.Command{Channel: "general", Author: author, Cmd: cmd.Name, Args: args},
+		Store:   s,
+	}
+	if cmd.Args != nil {
+		params, err := cmd.Args.Parse(args)
+		if err != nil {
+			return "error: " + err.Error()
+		}
+		req.Params = params
+	}
+	rec := &Recorder{}
+	cmd.Handler(context.Background(), req, rec)
+	return strings.Join(rec.Replies(), "\n")
+}
+
+// Client is a client.Client keeping the text of the messages sent.
+type Client struct {
+	mu   sync.Mutex
+	sent []string
+
+	// Failures is the number of sends to fail before sending succeeds.
+	Failures int
+	// Cancel, when set, is called instead of sending, as if the sender was
+	// stopped.
+	Cancel context.CancelFunc
+}
+
+var _ client.Client = (*Client)(nil)
+
+func (c *Client) Send(ctx context.Context, event cloudevents.Event) (*cloudevents.Event, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Cancel != nil {
+		c.Cancel()
+		return nil, ctx.Err()
+	}
+	if c.Failures > 0 {
+		c.Failures--
+		return nil, errors.New("unavailable")
+	}
+	// Events built with commands.ResponseEvent carry the message as is.
+	msg, ok := event.Data.(events.Message)
+	if !ok {
+		if err := event.DataAs(&msg); err != nil {
+			return nil, err
+		}
+	}
+	c.sent = append(c.sent, msg.Text)
+	return nil, nil
+}
+
+func (c *Client) StartReceiver(ctx context.Context, fn interface{}) error {
+	return nil
+}
+
+func (c *Client) StopReceiver(ctx context.Context) error {
+	return nil
+}
+
+// Messages returns the text of the messages sent so far.
+func (c *Client) Messages() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.sent...)
+}
+
+// SetCancel sets Cancel while sends may be in flight.
+func (c *Client) SetCancel(cancel context.CancelFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Cancel = cancel
+}
+
+// Clock is a settable time, for packages that read the time from a
+// replaceable func:
+//
+//	clock := commandstest.NewClock(start)
+//	now = clock.Now
+//	defer func() { now = time.Now }()
+type Clock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+// NewClock returns a Clock at t.
+func NewClock(t time.Time) *Clock {
+	return &Clock{t: t}
+}
+
+// Now returns the time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+// Set moves the clock to t.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = t
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"fmt"
+	"github.com/botless/commands/pkg/commands"
+	"github.com/botless/commands/pkg/ratelimit"
+	"net/url"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands.MustRegister(commands.Command{
+		Name:        "echo",
+		Description: "Repeats the given text back to the channel.",
+		Usage:       "echo <text>",
+		Examples:    []string{"echo hello world"},
+		Handler:     commands.Text(Echo),
+	})
+	commands.MustRegister(commands.Command{
+		Name:        "caps",
+		Description: "Repeats the given text in upper case.",
+		Usage:       "caps <text>",
+		Examples:    []string{"caps hello world"},
+		Aliases:     []string{"shout"},
+		Handler:     commands.Text(Caps),
+	})
+	commands.MustRegister(commands.Command{
+		Name:        "flip",
+		Description: "Flips a table over the given text.",
+		Usage:       "flip <text>",
+		Examples:    []string{"flip mondays"},
+		Aliases:     []string{"tableflip"},
+		RateLimits: &commands.RateLimits{
+			PerAuthor:  ratelimit.Limit{Events: 3, Per: time.Minute},
+			PerChannel: ratelimit.Limit{Events: 10, Per: time.Minute},
+		},
+		Handler: commands.Text(Flip),
+	})
+}
+
+func Echo(args string) (string, error) {
+	return args, nil
+}
+
+func Caps(args string) (string, error) {
+	return strings.ToUpper(args), nil
+}
+
+func Flip(args string) (string, error) {
+	return fmt.Sprintf("https://tableflip.dev/?flip=%s", url.QueryEscape(args)), nil
+}
@@ -0,0 +1,181 @@
+package commands
+
+import (
+	"context"
+	"github.com/botless/commands/pkg/logging"
+	"github.com/botless/commands/pkg/store"
+	"github.com/botless/events/pkg/events"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"time"
+)
+
+const (
+	defaultDeliverInterval    = 10 * time.Second
+	defaultDeliverMaxAttempts = 5
+
+	// ClaimTTL is how long a claim is held. A replica that dies holding one
+	// leaves the item to another replica once it expires.
+	ClaimTTL = 5 * time.Minute
+	// claimPrefix is the key prefix of claims in their namespace.
+	claimPrefix = "claim/"
+	// finishTimeout bounds the store updates after a send, which must be
+	// made even when stopping.
+	finishTimeout = 10 * time.Second
+)
+
+// Claim takes the claim on id in the namespace ns of s for ClaimTTL, so
+// replicas sharing s do not work on id at the same time. It reports false
+// when the claim is held by another.
+func Claim(ctx context.Context, s store.Store, ns, id string) (bool, error) {
+	return s.SetIfAbsent(ctx, ns, claimPrefix+id, nil, ClaimTTL)
+}
+
+// Release lets id be claimed again.
+func Release(ctx context.Context, s store.Store, ns, id string) error {
+	return s.Delete(ctx, ns, claimPrefix+id)
+}
+
+// Delivery is a message taken from the store to be sent.
+type Delivery struct {
+	Message events.Message
+	// Attempts is the number of failed deliveries so far.
+	Attempts int
+
+	// Sent is called once Message is sent. Optional.
+	Sent func(ctx context.Context) error
+	// Failed is called when Message could not be sent, with the failed
+	// deliveries so far and whether it is to be retried. Optional.
+	Failed func(ctx context.Context, attempts int, retry bool) error
+}
+
+// Deliverer sends the messages of work that falls due later, such as
+// reminders, from every replica. Each due item is claimed in Store before
+// it is taken, so as long as the replicas share the Store only one of them
+// delivers it.
+type Deliverer struct {
+	Store  store.Store
+	Sender *Sender
+
+	// Namespace holds the claims, and names the command the messages are
+	// sent from.
+	Namespace string
+	// Name is what an item is called in logs, e.g. "reminder".
+	Name string
+
+	// Due returns the ids of the items due now, in delivery order.
+	Due func(ctx context.Context) ([]string, error)
+	// Take returns the delivery of the claimed item id, or nil when it is
+	// no longer due.
+	Take func(ctx context.Context, id string) (*Delivery, error)
+
+	// Interval is how often due items are looked for. Defaults to 10s.
+	Interval time.Duration
+	// MaxAttempts is the number of times a delivery is tried, once per
+	// Interval, before it is given up. Defaults to 5.
+	MaxAttempts int
+
+	// Logger defaults to logging.Default().
+	Logger *logging.Logger
+}
+
+// Run delivers due items until ctx is done.
+func (d *Deliverer) Run(ctx context.Context) {
+	interval := d.Interval
+	if interval <= 0 {
+		interval = defaultDeliverInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		d.DeliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Deliverer) logger() *logging.Logger {
+	if d.Logger != nil {
+		return d.Logger
+	}
+	return logging.Default()
+}
+
+// DeliverDue delivers the items due now.
+func (d *Deliverer) DeliverDue(ctx context.Context) {
+	ids, err := d.Due(ctx)
+	if err != nil {
+		d.logger().Errorf("failed to list %ss: %s", d.Name, err)
+		return
+	}
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+		d.deliver(ctx, id)
+	}
+}
+
+// deliver claims, takes and sends the item id.
+func (d *Deliverer) deliver(ctx context.Context, id string) {
+	logger := d.logger().With(d.Name, id)
+	claimed, err := Claim(ctx, d.Store, d.Namespace, id)
+	if err != nil {
+		logger.Errorf("failed to claim %s: %s", d.Name, err)
+		return
+	}
+	if !claimed {
+		// Being delivered by another replica.
+		return
+	}
+	// Taking may change the store, what follows must be done even when
+	// ctx is done.
+	detached, cancel := context.WithTimeout(context.Background(), finishTimeout)
+	defer cancel()
+	defer func() {
+		if err := Release(detached, d.Store, d.Namespace, id); err != nil {
+			logger.Errorf("failed to release %s: %s", d.Name, err)
+		}
+	}()
+
+	delivery, err := d.Take(ctx, id)
+	if err != nil || delivery == nil {
+		if err != nil {
+			logger.Errorf("failed to take %s: %s", d.Name, err)
+		}
+		return
+	}
+	logger = logger.With("channel", delivery.Message.Channel)
+	event := ResponseEvent(d.Namespace, cloudevents.Event{}, delivery.Message)
+	if err := d.Sender.Send(ctx, event); err != nil {
+		attempts := delivery.Attempts
+		// Being stopped is not a failed delivery.
+		if ctx.Err() == nil {
+			attempts++
+		}
+		max := d.MaxAttempts
+		if max <= 0 {
+			max = defaultDeliverMaxAttempts
+		}
+		retry := attempts < max
+		if retry {
+			logger.Warnf("failed to deliver %s, attempt %d of %d: %s", d.Name, attempts, max, err)
+		} else {
+			logger.Errorf("giving up on %s after %d failed deliveries: %s", d.Name, attempts, err)
+		}
+		if delivery.Failed != nil {
+			if err := delivery.Failed(detached, attempts, retry); err != nil {
+				logger.Errorf("failed to save failed %s: %s", d.Name, err)
+			}
+		}
+		return
+	}
+	logger.Infof("delivered %s", d.Name)
+	if delivery.Sent != nil {
+		if err := delivery.Sent(detached); err != nil {
+			logger.Errorf("failed to save delivered %s: %s", d.Name, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"context"
+	"github.com/botless/commands/pkg/logging"
+	"github.com/botless/commands/pkg/store"
+	"github.com/botless/events/pkg/events"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestDeliverer(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	ce := &failingClient{failures: 2}
+	var got []string
+	attempts := map[string]int{}
+	d := &Deliverer{
+		Store:     s,
+		Sender:    &Sender{Ce: ce},
+		Namespace: "test",
+		Name:      "item",
+		Due: func(ctx context.Context) ([]string, error) {
+			return []string{"a", "b"}, nil
+		},
+		Take: func(ctx context.Context, id string) (*Delivery, error) {
+			return &Delivery{
+				Message:  events.Message{Channel: "general", Text: id},
+				Attempts: attempts[id],
+				Sent: func(ctx context.Context) error {
+					got = append(got, "sent "+id)
+					return nil
+				},
+				Failed: func(ctx context.Context, n int, retry bool) error {
+					attempts[id] = n
+					if !retry {
+						got = append(got, "gave up "+id)
+					}
+					return nil
+				},
+			}, nil
+		},
+		MaxAttempts: 2,
+		Logger:      logging.New(ioutil.Discard, logging.Error, false),
+	}
+
+	// b is being delivered by another replica.
+	if claimed, err := Claim(ctx, s, "test", "b"); !claimed || err != nil {
+		t.Fatalf("Claim(b) = %t, %v", claimed, err)
+	}
+	d.DeliverDue(ctx)
+	d.DeliverDue(ctx)
+	if want := []string{"gave up a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := attempts["a"]; got != 2 {
+		t.Errorf("attempts of a = %d, want 2", got)
+	}
+
+	// Once released b is delivered, and a is released too.
+	if err := Release(ctx, s, "test", "b"); err != nil {
+		t.Fatal(err)
+	}
+	delete(attempts, "a")
+	got = nil
+	d.DeliverDue(ctx)
+	if want := []string{"sent a", "sent b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
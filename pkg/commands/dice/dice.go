@@ -0,0 +1,103 @@
+// Package dice implements the roll command, which rolls dice written in RPG
+// notation, e.g. "3d6+2", "4d6kh3" or "d20 adv".
+package dice
+
+import (
+	"fmt"
+	"github.com/botless/commands/pkg/commands"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	roller := NewRoller(rand.NewSource(time.Now().UnixNano()))
+	commands.MustRegister(commands.Command{
+		Name:        "roll",
+		Description: "Rolls dice, e.g. 3d6+2, 4d6kh3, d20 adv or 6d6!.",
+		Usage:       "roll <dice>...",
+		Examples:    []string{"roll 3d6+2", "roll 4d6kh3", "roll d20 adv", "roll 2d6! 1d8+3"},
+		Handler:     commands.Text(roller.Text),
+	})
+}
+
+const (
+	// MaxDice is the number of dice a single roll may use, including dice
+	// added by explosions.
+	MaxDice = 1000
+	// MaxSides is the most sides a die may have.
+	MaxSides = 1000
+	// MaxExpressions is the number of expressions rolled at once.
+	MaxExpressions = 10
+	// maxExplosions bounds how often a single die explodes.
+	maxExplosions = 100
+	// maxNumber is the largest number an expression may contain.
+	maxNumber = 1000000
+	// maxTotal bounds every intermediate result, well within an int on
+	// any platform.
+	maxTotal = 1000000000
+	// maxShown is the number of dice shown per term of a result.
+	maxShown = 50
+)
+
+// Roller rolls dice expressions. It is safe for concurrent use.
+type Roller struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewRoller returns a Roller drawing from src. A fixed source makes rolls
+// repeatable.
+func NewRoller(src rand.Source) *Roller {
+	return &Roller{rnd: rand.New(src)}
+}
+
+// Result is a rolled expression.
+type Result struct {
+	// Expression is the expression as parsed, e.g. "2d20kh1".
+	Expression string
+	// Detail shows each die and the arithmetic, e.g. "[14, ~3~] + 2".
+	Detail string
+	Total  int
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("`%s` → %s = *%d*", r.Expression, r.Detail, r.Total)
+}
+
+// Roll parses and rolls the expressions in s.
+func (r *Roller) Roll(s string) ([]Result, error) {
+	exprs, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	budget := MaxDice
+	results := make([]Result, 0, len(exprs))
+	for _, e := range exprs {
+		detail, total, err := e.eval(r.rnd, &budget)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", e, err)
+		}
+		results = append(results, Result{Expression: e.String(), Detail: detail, Total: total})
+	}
+	return results, nil
+}
+
+// Text rolls s and formats the results one per line.
+func (r *Roller) Text(s string) (string, error) {
+	if strings.TrimSpace(s) == "" {
+		s = "d20"
+	}
+	results, err := r.Roll(s)
+	if err != nil {
+		return "", err
+	}
+	lines := make([]string, len(results))
+	for i, res := range results {
+		lines[i] = res.String()
+	}
+	return strings.Join(lines, "\n"), nil
+}
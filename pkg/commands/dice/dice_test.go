@@ -0,0 +1,211 @@
+package dice
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// script is a rand.Source making Intn(sides)+1 return the given rolls in
+// order, for sides above every roll.
+type script []int
+
+func (s *script) Int63() int64 {
+	v := (*s)[0]
+	*s = (*s)[1:]
+	return int64(v-1) << 32
+}
+
+func (s *script) Seed(int64) {}
+
+func roll(t *testing.T, rolls []int, expr string) []Result {
+	t.Helper()
+	src := script(rolls)
+	results, err := NewRoller(&src).Roll(expr)
+	if err != nil {
+		t.Fatalf("Roll(%q) error = %v", expr, err)
+	}
+	if len(src) != 0 {
+		t.Errorf("Roll(%q) left rolls %v", expr, []int(src))
+	}
+	return results
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"d20", "1d20"},
+		{"3d6+2", "3d6+2"},
+		{"D%", "1d100"},
+		{"4d6kh3", "4d6kh3"},
+		{"4d6k3", "4d6kh3"},
+		{"2d20kl1", "2d20kl1"},
+		{"4d6dl1", "4d6kh3"},
+		{"4d6dh1", "4d6kl3"},
+		{"6d6!", "6d6!"},
+		{"2d6!kh1", "2d6!kh1"},
+		{"d20 adv", "2d20kh1"},
+		{"d20+5 dis", "2d20kl1+5"},
+		{"(1d4+1)*2", "(1d4+1)*2"},
+		{"-d4", "-1d4"},
+		{"3d6+2, d20", "3d6+2 1d20"},
+		{"2d6 d8; 4", "2d6 1d8 4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			exprs, err := Parse(tt.s)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got := make([]string, len(exprs))
+			for i, e := range exprs {
+				got[i] = e.String()
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("Parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"", "nothing to roll"},
+		{"d1", "d1: sides must be between 2 and 1000"},
+		{"d1001", "d1001: sides must be between 2 and 1000"},
+		{"0d6", "0d6: count must be between 1 and 1000"},
+		{"1001d6", "1001d6: count must be between 1 and 1000"},
+		{"4d6kh5", "4d6kh5: can only keep or drop 1 to 4 dice"},
+		{"4d6dl4", "4d6dl4: can not drop every die"},
+		{"1000001", "number 1000001 is too large"},
+		{"d20 + ", "unexpected end of expression"},
+		{"(d20", "missing )"},
+		{"d20)", `unexpected ")"`},
+		{"d20 x", `unexpected "x"`},
+		{"d20 lucky", `unexpected "lucky"`},
+		{"2d20 adv", "adv needs a single die to roll, e.g. `d20 adv`"},
+		{"1,2,3,4,5,6,7,8,9,10,11", "at most 10 expressions can be rolled at once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			if _, err := Parse(tt.s); err == nil || err.Error() != tt.want {
+				t.Errorf("Parse() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollLimits(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"1000000*1000000*1000000*1000000", "1000000*1000000*1000000*1000000: result is too large, at most 1000000000"},
+		{"1000000*1000000", "1000000*1000000: result is too large, at most 1000000000"},
+		{"-1000000*1000000", "-1000000*1000000: result is too large, at most 1000000000"},
+		{"1000000*1000+1", "1000000*1000+1: result is too large, at most 1000000000"},
+		{"4/(2-2)", "4/(2-2): division by zero"},
+		{"600d6 600d6", "600d6: too many dice, at most 1000 can be rolled at once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			_, err := NewRoller(rand.NewSource(1)).Roll(tt.s)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Roll() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+	// The largest results are fine.
+	results, err := NewRoller(rand.NewSource(1)).Roll("1000000*1000, -1000000*1000")
+	if err != nil || results[0].Total != 1000000000 || results[1].Total != -1000000000 {
+		t.Errorf("Roll() = %v, %v, want ±1000000000", results, err)
+	}
+}
+
+func TestRoll(t *testing.T) {
+	tests := []struct {
+		name  string
+		rolls []int
+		s     string
+		want  string
+	}{
+		{"arithmetic", []int{3, 4, 5}, "3d6+2", "`3d6+2` → [3, 4, 5] + 2 = *14*"},
+		{"precedence", []int{3}, "1+d4*2-(6/4)", "`1+1d4*2-(6/4)` → 1 + [3] * 2 - (6 / 4) = *6*"},
+		{"negative", []int{3}, "-d4", "`-1d4` → -[3] = *-3*"},
+		{"keep highest", []int{5, 1, 6, 3}, "4d6kh3", "`4d6kh3` → [5, ~1~, 6, 3] = *14*"},
+		{"keep lowest", []int{5, 1, 6, 3}, "4d6kl2", "`4d6kl2` → [~5~, 1, ~6~, 3] = *4*"},
+		{"drop lowest", []int{2, 2, 4}, "3d6dl1", "`3d6kh2` → [2, ~2~, 4] = *6*"},
+		{"advantage", []int{7, 15}, "d20 adv", "`2d20kh1` → [~7~, 15] = *15*"},
+		{"disadvantage", []int{7, 15}, "d20+2 dis", "`2d20kl1+2` → [7, ~15~] + 2 = *9*"},
+		{"explode", []int{6, 6, 2, 3}, "2d6!", "`2d6!` → [6!+6!+2, 3] = *17*"},
+		// A chain is one die, kept or dropped as a whole.
+		{"explode keep", []int{6, 1, 5}, "2d6!kh1", "`2d6!kh1` → [6!+1, ~5~] = *7*"},
+		{"explode drop", []int{6, 1, 5, 2}, "3d6!dl1", "`3d6!kh2` → [6!+1, 5, ~2~] = *12*"},
+		{"explode advantage", []int{20, 3, 19}, "d20! adv", "`2d20!kh1` → [20!+3, ~19~] = *23*"},
+		{"several", []int{4, 2}, "d6, d8 3", "`1d6` → [4] = *4*\n`1d8` → [2] = *2*\n`3` → 3 = *3*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := script(tt.rolls)
+			got, err := NewRoller(&src).Text(tt.s)
+			if err != nil {
+				t.Fatalf("Text() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Text() = %q, want %q", got, tt.want)
+			}
+			if len(src) != 0 {
+				t.Errorf("Text() left rolls %v", []int(src))
+			}
+		})
+	}
+}
+
+func TestRollExplosionBudget(t *testing.T) {
+	// Every roll explodes, the chain stops at maxExplosions.
+	rolls := make([]int, maxExplosions+1)
+	for i := range rolls {
+		rolls[i] = 2
+	}
+	results := roll(t, rolls, "d2!")
+	if want := 2 * (maxExplosions + 1); results[0].Total != want {
+		t.Errorf("Total = %d, want %d", results[0].Total, want)
+	}
+}
+
+func TestRollSeeded(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		// The same source rolls the same.
+		a, err := NewRoller(rand.NewSource(seed)).Roll("4d6kh3, 2d20kl1, 3d6!, d20 adv")
+		if err != nil {
+			t.Fatalf("Roll() error = %v", err)
+		}
+		b, _ := NewRoller(rand.NewSource(seed)).Roll("4d6kh3, 2d20kl1, 3d6!, d20 adv")
+		for i := range a {
+			if a[i] != b[i] {
+				t.Errorf("seed %d: rolled %v then %v", seed, a[i], b[i])
+			}
+		}
+		bounds := []struct{ min, max int }{{3, 18}, {1, 20}, {3, 3 * 6 * (maxExplosions + 1)}, {1, 20}}
+		for i, r := range a {
+			if r.Total < bounds[i].min || r.Total > bounds[i].max {
+				t.Errorf("seed %d: %s = %d, want between %d and %d", seed, r.Expression, r.Total, bounds[i].min, bounds[i].max)
+			}
+		}
+		if n := strings.Count(a[0].Detail, "~"); n != 2 {
+			t.Errorf("seed %d: %s dropped %s, want one die", seed, a[0].Expression, a[0].Detail)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	got, err := NewRoller(rand.NewSource(1)).Text("  ")
+	if err != nil || !strings.HasPrefix(got, "`1d20` → [") {
+		t.Errorf("Text() = %q, %v, want a d20 roll", got, err)
+	}
+}
@@ -0,0 +1,479 @@
+package dice
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Expr is a parsed dice expression.
+type Expr interface {
+	fmt.Stringer
+	// eval rolls the expression, drawing dice from budget.
+	eval(rnd *rand.Rand, budget *int) (detail string, total int, err error)
+}
+
+// Parse parses s into one or more expressions. Expressions are separated by
+// commas or simply by white space, e.g. "3d6+2, d20" or "2d6 d8". A trailing
+// "adv" or "dis" rolls each single die of the expression before it twice,
+// keeping the highest or lowest.
+//
+// Dice are written [count]d<sides|%>[!][kh|kl|dh|dl<n>]: "!" explodes dice
+// rolling their highest side, adding another roll to the die, "kh" and "kl"
+// keep the highest or lowest n dice and "dh" and "dl" drop them. Dice and numbers combine with
+// + - * / and parentheses.
+func Parse(s string) ([]Expr, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("nothing to roll")
+	}
+	p := &parser{tokens: tokens}
+	var exprs []Expr
+	for p.pos < len(p.tokens) {
+		if p.peek().kind == tokComma {
+			p.pos++
+			continue
+		}
+		if len(exprs) == MaxExpressions {
+			return nil, fmt.Errorf("at most %d expressions can be rolled at once", MaxExpressions)
+		}
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.peek(); t.kind == tokWord {
+			p.pos++
+			if err := advantage(e, t.text); err != nil {
+				return nil, err
+			}
+		}
+		exprs = append(exprs, e)
+	}
+	return exprs, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokDice
+	tokOp
+	tokOpen
+	tokClose
+	tokComma
+	tokWord
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	dice *Dice
+}
+
+var (
+	dicePattern   = regexp.MustCompile(`^(\d*)d(\d+|%)(!)?(?:(kh|kl|dh|dl|k)(\d+))?`)
+	numberPattern = regexp.MustCompile(`^\d+`)
+	wordPattern   = regexp.MustCompile(`^[a-z]+`)
+)
+
+func lex(s string) ([]token, error) {
+	s = strings.ToLower(s)
+	var tokens []token
+	for len(s) > 0 {
+		switch c := s[0]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			s = s[1:]
+		case c == '+' || c == '-' || c == '*' || c == '/':
+			tokens = append(tokens, token{kind: tokOp, text: string(c)})
+			s = s[1:]
+		case c == '(':
+			tokens = append(tokens, token{kind: tokOpen, text: "("})
+			s = s[1:]
+		case c == ')':
+			tokens = append(tokens, token{kind: tokClose, text: ")"})
+			s = s[1:]
+		case c == ',' || c == ';':
+			tokens = append(tokens, token{kind: tokComma, text: ","})
+			s = s[1:]
+		case dicePattern.MatchString(s):
+			m := dicePattern.FindStringSubmatch(s)
+			d, err := newDice(m)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokDice, text: m[0], dice: d})
+			s = s[len(m[0]):]
+		case numberPattern.MatchString(s):
+			n := numberPattern.FindString(s)
+			tokens = append(tokens, token{kind: tokNumber, text: n})
+			s = s[len(n):]
+		case wordPattern.MatchString(s):
+			w := wordPattern.FindString(s)
+			switch w {
+			case "adv", "advantage", "dis", "disadvantage":
+				tokens = append(tokens, token{kind: tokWord, text: w})
+			default:
+				return nil, fmt.Errorf("unexpected %q", w)
+			}
+			s = s[len(w):]
+		default:
+			return nil, fmt.Errorf("unexpected %q", s[:1])
+		}
+	}
+	return tokens, nil
+}
+
+func newDice(m []string) (*Dice, error) {
+	d := &Dice{Count: 1, Explode: m[3] == "!"}
+	if m[1] != "" {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n < 1 || n > MaxDice {
+			return nil, fmt.Errorf("%s: count must be between 1 and %d", m[0], MaxDice)
+		}
+		d.Count = n
+	}
+	if m[2] == "%" {
+		d.Sides = 100
+	} else {
+		n, err := strconv.Atoi(m[2])
+		if err != nil || n < 2 || n > MaxSides {
+			return nil, fmt.Errorf("%s: sides must be between 2 and %d", m[0], MaxSides)
+		}
+		d.Sides = n
+	}
+	if m[4] != "" {
+		n, err := strconv.Atoi(m[5])
+		if err != nil || n < 1 || n > d.Count {
+			return nil, fmt.Errorf("%s: can only keep or drop 1 to %d dice", m[0], d.Count)
+		}
+		switch m[4] {
+		case "k", "kh":
+			d.KeepHighest = n
+		case "kl":
+			d.KeepLowest = n
+		case "dh":
+			d.KeepLowest = d.Count - n
+		case "dl":
+			d.KeepHighest = d.Count - n
+		}
+		if d.KeepHighest == 0 && d.KeepLowest == 0 {
+			return nil, fmt.Errorf("%s: can not drop every die", m[0])
+		}
+	}
+	return d, nil
+}
+
+// parser is a recursive descent parser over the grammar
+//
+//	expr   = term { ("+" | "-") term }
+//	term   = unary { ("*" | "/") unary }
+//	unary  = "-" unary | atom
+//	atom   = number | dice | "(" expr ")"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return token{kind: tokEOF}
+}
+
+func (p *parser) expr() (Expr, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.kind == tokOp && (t.text == "+" || t.text == "-"); t = p.peek() {
+		p.pos++
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: t.text, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) term() (Expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.kind == tokOp && (t.text == "*" || t.text == "/"); t = p.peek() {
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: t.text, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (Expr, error) {
+	if t := p.peek(); t.kind == tokOp && t.text == "-" {
+		p.pos++
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &Binary{Op: "-", Left: Number(0), Right: e}, nil
+	}
+	return p.atom()
+}
+
+func (p *parser) atom() (Expr, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokNumber:
+		p.pos++
+		n, err := strconv.Atoi(t.text)
+		if err != nil || n > maxNumber {
+			return nil, fmt.Errorf("number %s is too large", t.text)
+		}
+		return Number(n), nil
+	case tokDice:
+		p.pos++
+		return t.dice, nil
+	case tokOpen:
+		p.pos++
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokClose {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return &Group{Expr: e}, nil
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
+
+// advantage makes every single die of e roll twice, keeping the highest for
+// "adv" and the lowest for "dis".
+func advantage(e Expr, word string) error {
+	found := false
+	var walk func(e Expr)
+	walk = func(e Expr) {
+		switch e := e.(type) {
+		case *Dice:
+			if e.Count == 1 && e.KeepHighest == 0 && e.KeepLowest == 0 {
+				e.Count = 2
+				if strings.HasPrefix(word, "adv") {
+					e.KeepHighest = 1
+				} else {
+					e.KeepLowest = 1
+				}
+				found = true
+			}
+		case *Binary:
+			walk(e.Left)
+			walk(e.Right)
+		case *Group:
+			walk(e.Expr)
+		}
+	}
+	walk(e)
+	if !found {
+		return fmt.Errorf("%s needs a single die to roll, e.g. `d20 %s`", word, word)
+	}
+	return nil
+}
+
+// Number is a constant.
+type Number int
+
+func (n Number) String() string {
+	return strconv.Itoa(int(n))
+}
+
+func (n Number) eval(*rand.Rand, *int) (string, int, error) {
+	return n.String(), int(n), nil
+}
+
+// Binary applies an arithmetic operator.
+type Binary struct {
+	Op          string
+	Left, Right Expr
+}
+
+func (b *Binary) String() string {
+	if n, ok := b.Left.(Number); ok && n == 0 && b.Op == "-" {
+		return "-" + b.Right.String()
+	}
+	return b.Left.String() + b.Op + b.Right.String()
+}
+
+func (b *Binary) eval(rnd *rand.Rand, budget *int) (string, int, error) {
+	ld, l, err := b.Left.eval(rnd, budget)
+	if err != nil {
+		return "", 0, err
+	}
+	rd, r, err := b.Right.eval(rnd, budget)
+	if err != nil {
+		return "", 0, err
+	}
+	if n, ok := b.Left.(Number); ok && n == 0 && b.Op == "-" {
+		return "-" + rd, -r, nil
+	}
+	detail := ld + " " + b.Op + " " + rd
+	var n int
+	switch b.Op {
+	case "+":
+		n = l + r
+	case "-":
+		n = l - r
+	case "*":
+		// Checked before multiplying, the product could overflow.
+		if l != 0 && abs(r) > maxTotal/abs(l) {
+			return "", 0, errTooLarge
+		}
+		n = l * r
+	default:
+		if r == 0 {
+			return "", 0, fmt.Errorf("division by zero")
+		}
+		n = l / r
+	}
+	if abs(n) > maxTotal {
+		return "", 0, errTooLarge
+	}
+	return detail, n, nil
+}
+
+var errTooLarge = fmt.Errorf("result is too large, at most %d", maxTotal)
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Group is a parenthesized expression.
+type Group struct {
+	Expr Expr
+}
+
+func (g *Group) String() string {
+	return "(" + g.Expr.String() + ")"
+}
+
+func (g *Group) eval(rnd *rand.Rand, budget *int) (string, int, error) {
+	d, n, err := g.Expr.eval(rnd, budget)
+	return "(" + d + ")", n, err
+}
+
+// Dice rolls Count dice with Sides sides.
+type Dice struct {
+	Count, Sides int
+	// Explode rolls a die again while it shows its highest side, adding
+	// the rolls up before dice are kept or dropped.
+	Explode bool
+	// KeepHighest or KeepLowest keep only that many dice when not zero.
+	KeepHighest, KeepLowest int
+}
+
+func (d *Dice) String() string {
+	s := fmt.Sprintf("%dd%d", d.Count, d.Sides)
+	if d.Explode {
+		s += "!"
+	}
+	switch {
+	case d.KeepHighest > 0:
+		s += fmt.Sprintf("kh%d", d.KeepHighest)
+	case d.KeepLowest > 0:
+		s += fmt.Sprintf("kl%d", d.KeepLowest)
+	}
+	return s
+}
+
+// die is a single rolled die. An exploding die keeps rolling while it shows
+// its highest side, its value is the sum of the rolls.
+type die struct {
+	rolls   []int
+	value   int
+	dropped bool
+}
+
+func (d die) String() string {
+	shown := make([]string, len(d.rolls))
+	for i, v := range d.rolls {
+		shown[i] = strconv.Itoa(v)
+		if i < len(d.rolls)-1 {
+			shown[i] += "!"
+		}
+	}
+	s := strings.Join(shown, "+")
+	if d.dropped {
+		s = "~" + s + "~"
+	}
+	return s
+}
+
+func (d *Dice) eval(rnd *rand.Rand, budget *int) (string, int, error) {
+	roll := func() (int, error) {
+		if *budget == 0 {
+			return 0, fmt.Errorf("too many dice, at most %d can be rolled at once", MaxDice)
+		}
+		*budget--
+		return rnd.Intn(d.Sides) + 1, nil
+	}
+	dice := make([]die, d.Count)
+	for i := range dice {
+		v, err := roll()
+		if err != nil {
+			return "", 0, err
+		}
+		dice[i] = die{rolls: []int{v}, value: v}
+		for explosions := 0; d.Explode && v == d.Sides && explosions < maxExplosions; explosions++ {
+			if v, err = roll(); err != nil {
+				return "", 0, err
+			}
+			dice[i].rolls = append(dice[i].rolls, v)
+			dice[i].value += v
+		}
+	}
+
+	if keep := d.KeepHighest + d.KeepLowest; keep > 0 && keep < len(dice) {
+		order := make([]int, len(dice))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			if d.KeepHighest > 0 {
+				return dice[order[i]].value > dice[order[j]].value
+			}
+			return dice[order[i]].value < dice[order[j]].value
+		})
+		for _, i := range order[keep:] {
+			dice[i].dropped = true
+		}
+	}
+
+	total := 0
+	shown := make([]string, len(dice))
+	for i, die := range dice {
+		if !die.dropped {
+			total += die.value
+		}
+		shown[i] = die.String()
+	}
+	if len(shown) > maxShown {
+		shown = append(shown[:maxShown], fmt.Sprintf("… %d more", len(shown)-maxShown))
+	}
+	return "[" + strings.Join(shown, ", ") + "]", total, nil
+}
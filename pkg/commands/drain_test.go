@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"context"
+	"github.com/botless/events/pkg/events"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReceiveDraining(t *testing.T) {
+	c := &Commands{Ce: &failingClient{}, Registry: testRegistry(t)}
+	if err := c.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+	if resp := receive(t, c, "echo hi"); resp.Status != http.StatusServiceUnavailable || resp.Reason != "shutting down" {
+		t.Errorf("Receive() response = %d %q, want 503 shutting down", resp.Status, resp.Reason)
+	}
+	resp := &cloudevents.EventResponse{}
+	c.ReceiveAndReply(context.Background(), commandEvent(t, events.Command{Cmd: "echo", Args: "hi"}), resp)
+	if resp.Status != http.StatusServiceUnavailable {
+		t.Errorf("ReceiveAndReply() status = %d, want 503", resp.Status)
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	ce := &failingClient{}
+	c := &Commands{Ce: ce, Registry: blockingRegistry(t, started, release), Workers: 1}
+	receive(t, c, "wait")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.Drain(ctx); err == nil || !strings.Contains(err.Error(), "still in flight") {
+		t.Errorf("Drain() error = %v, want commands still in flight", err)
+	}
+
+	// The event in flight is still handled.
+	close(release)
+	if err := c.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+	if len(ce.sent) != 1 {
+		t.Errorf("sent %d replies, want 1", len(ce.sent))
+	}
+}
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// TypeFilter selects CloudEvent types by a list of glob patterns. Patterns
+// prefixed with "!" exclude matching types. A type is selected when it is not
+// excluded and either matches an include pattern or no include patterns are
+// given.
+type TypeFilter struct {
+	include []string
+	exclude []string
+}
+
+// ParseTypeFilter parses a comma separated list of patterns, e.g.
+// "botless.bot.command.*,!botless.bot.command.flip". Patterns use path.Match
+// syntax.
+func ParseTypeFilter(s string) (*TypeFilter, error) {
+	f := &TypeFilter{}
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		exclude := strings.HasPrefix(p, "!")
+		if exclude {
+			p = strings.TrimSpace(p[1:])
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid type pattern %q: %s", p, err)
+		}
+		if exclude {
+			f.exclude = append(f.exclude, p)
+		} else {
+			f.include = append(f.include, p)
+		}
+	}
+	return f, nil
+}
+
+// Match reports whether t is selected. A nil filter selects every type.
+func (f *TypeFilter) Match(t string) bool {
+	if f == nil {
+		return true
+	}
+	for _, p := range f.exclude {
+		if ok, _ := path.Match(p, t); ok {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, p := range f.include {
+		if ok, _ := path.Match(p, t); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *TypeFilter) String() string {
+	if f == nil {
+		return ""
+	}
+	patterns := append([]string(nil), f.include...)
+	for _, p := range f.exclude {
+		patterns = append(patterns, "!"+p)
+	}
+	return strings.Join(patterns, ",")
+}
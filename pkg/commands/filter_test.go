@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"testing"
+)
+
+func TestTypeFilter(t *testing.T) {
+	const (
+		echo = "botless.bot.command.echo"
+		flip = "botless.bot.command.flip"
+		chat = "botless.slack.message"
+	)
+	tests := []struct {
+		spec   string
+		types  []string
+		want   []bool
+		string string
+	}{
+		{"", []string{echo, chat}, []bool{true, true}, ""},
+		{" , ", []string{echo}, []bool{true}, ""},
+		{"botless.bot.command.*", []string{echo, flip, chat}, []bool{true, true, false}, "botless.bot.command.*"},
+		{"botless.bot.command.*,!botless.bot.command.flip", []string{echo, flip, chat}, []bool{true, false, false}, "botless.bot.command.*,!botless.bot.command.flip"},
+		{"! botless.bot.command.flip", []string{echo, flip, chat}, []bool{true, false, true}, "!botless.bot.command.flip"},
+		{"botless.bot.command.echo, botless.slack.*", []string{echo, flip, chat}, []bool{true, false, true}, "botless.bot.command.echo,botless.slack.*"},
+		// Exclusions win over inclusions, whatever their order.
+		{"!botless.bot.command.*,botless.bot.command.flip", []string{flip}, []bool{false}, "botless.bot.command.flip,!botless.bot.command.*"},
+		// Unlike "/", dots are not separators: "*" spans them.
+		{"botless.*", []string{echo, chat}, []bool{true, true}, "botless.*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			f, err := ParseTypeFilter(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseTypeFilter() error = %v", err)
+			}
+			for i, typ := range tt.types {
+				if got := f.Match(typ); got != tt.want[i] {
+					t.Errorf("Match(%q) = %t, want %t", typ, got, tt.want[i])
+				}
+			}
+			if got := f.String(); got != tt.string {
+				t.Errorf("String() = %q, want %q", got, tt.string)
+			}
+		})
+	}
+}
+
+func TestTypeFilterNil(t *testing.T) {
+	var f *TypeFilter
+	if !f.Match("botless.bot.command.echo") {
+		t.Errorf("nil filter does not match")
+	}
+	if got := f.String(); got != "" {
+		t.Errorf("String() = %q, want empty", got)
+	}
+}
+
+func TestTypeFilterInvalid(t *testing.T) {
+	for _, spec := range []string{"botless.[", "echo,![a-"} {
+		if _, err := ParseTypeFilter(spec); err == nil {
+			t.Errorf("ParseTypeFilter(%q) succeeded", spec)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// helpCommand lists the commands of r, or details a single one, using the
+// same metadata the dispatcher routes on.
+func helpCommand(r *Registry) Command {
+	return Command{
+		Name:        "help",
+		Description: "Lists the available commands or shows how to use one.",
+		Usage:       "help [command]",
+		Examples:    []string{"help", "help echo"},
+		Handler: func(ctx context.Context, req *Request, resp Responder) {
+			name := strings.TrimSpace(req.Args)
+			if name == "" {
+				resp.Reply(r.summary())
+				return
+			}
+			cmd, ok := r.Lookup(name)
+			if !ok || !r.Enabled(cmd) {
+				resp.ReplyError(fmt.Errorf("unknown command %q, try `help` to list the available commands", name))
+				return
+			}
+			resp.Reply(cmd.help())
+		},
+	}
+}
+
+func (r *Registry) summary() string {
+	sb := strings.Builder{}
+	sb.WriteString("Available commands:\n")
+	for _, c := range r.Commands() {
+		if !r.Enabled(c) {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("• `%s` - %s\n", c.Name, c.Description))
+	}
+	sb.WriteString("Try `help <command>` for details.")
+	return sb.String()
+}
+
+func (c *Command) help() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("`%s` - %s", c.Name, c.Description))
+	usage := c.Usage
+	if usage == "" {
+		usage = c.Name
+	}
+	sb.WriteString(fmt.Sprintf("\nUsage: `%s`", usage))
+	if c.Args != nil {
+		if h := c.Args.Help(); h != "" {
+			sb.WriteString("\n" + h)
+		}
+	}
+	if len(c.Aliases) > 0 {
+		sb.WriteString(fmt.Sprintf("\nAliases: %s", strings.Join(c.Aliases, ", ")))
+	}
+	if len(c.Examples) > 0 {
+		sb.WriteString("\nExamples:")
+		for _, e := range c.Examples {
+			sb.WriteString(fmt.Sprintf("\n  `%s`", e))
+		}
+	}
+	return sb.String()
+}
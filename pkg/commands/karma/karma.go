@@ -0,0 +1,221 @@
+// Package karma implements the karma command, which keeps points users give
+// each other. Points are kept per Slack domain and channel.
+package karma
+
+import (
+	"context"
+	"fmt"
+	"github.com/botless/commands/pkg/commands"
+	"github.com/botless/commands/pkg/store"
+	"github.com/botless/events/pkg/events"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Namespace is the store namespace holding karma.
+	Namespace = "karma"
+
+	pointsPrefix   = "points/"
+	cooldownPrefix = "cooldown/"
+
+	defaultTop = 10
+	maxTop     = 50
+)
+
+var change = regexp.MustCompile(`^(.+?)(\+\+|--)$`)
+
+// Command returns the karma command. A user may change the karma of another
+// user once per cooldown.
+func Command(cooldown time.Duration) commands.Command {
+	return commands.Command{
+		Name:        "karma",
+		Description: "Gives or takes karma points, or shows them.",
+		Usage:       "karma @user++ [reason] | karma @user-- [reason] | karma @user | karma top [n]",
+		Examples: []string{
+			"karma @alice++",
+			"karma @alice++ for the review",
+			"karma @bob--",
+			"karma @alice",
+			"karma top",
+		},
+		Handler: func(ctx context.Context, req *commands.Request, resp commands.Responder) {
+			if req.Store == nil {
+				resp.ReplyError(fmt.Errorf("karma is not available, no store is configured"))
+				return
+			}
+			words := strings.Fields(req.Args)
+			who, delta, ok := parseChange(words)
+			switch {
+			case len(words) == 0 || strings.EqualFold(words[0], "top"):
+				top(ctx, req, resp, words)
+			case ok:
+				give(ctx, req, resp, who, delta, cooldown)
+			case len(words) == 1:
+				show(ctx, req, resp, words[0])
+			default:
+				resp.ReplyError(fmt.Errorf("usage: `karma @user++ [reason] | karma @user-- [reason] | karma @user | karma top [n]`"))
+			}
+		},
+	}
+}
+
+// parseChange reads "@user++" or "@user --" from the start of words, the
+// rest is free text such as a reason.
+func parseChange(words []string) (string, int64, bool) {
+	if len(words) == 0 {
+		return "", 0, false
+	}
+	who, op := words[0], ""
+	if m := change.FindStringSubmatch(who); m != nil {
+		who, op = m[1], m[2]
+	} else if len(words) > 1 && (words[1] == "++" || words[1] == "--") {
+		op = words[1]
+	}
+	switch op {
+	case "++":
+		return who, 1, true
+	case "--":
+		return who, -1, true
+	}
+	return "", 0, false
+}
+
+func give(ctx context.Context, req *commands.Request, resp commands.Responder, who string, delta int64, cooldown time.Duration) {
+	user := normalize(who)
+	if user == "" || strings.ContainsAny(user, " \t") {
+		resp.ReplyError(fmt.Errorf("invalid user %q", who))
+		return
+	}
+	if user == normalize(req.Author) {
+		resp.ReplyError(fmt.Errorf("nice try, you can not change your own karma"))
+		return
+	}
+	s := scope(req)
+	cooldownKey := cooldownPrefix + s + "#" + normalize(req.Author) + ">" + user
+	if cooldown > 0 {
+		// Set atomically, so concurrent changes can not both pass.
+		set, err := req.Store.SetIfAbsent(ctx, Namespace, cooldownKey, nil, cooldown)
+		if err != nil {
+			resp.ReplyError(fmt.Errorf("failed to check cooldown: %s", err))
+			return
+		}
+		if !set {
+			resp.Reply(fmt.Sprintf("easy there, you can change the karma of %s once every %s", commands.Mention(user), cooldown))
+			return
+		}
+	}
+	points, err := req.Store.Incr(ctx, Namespace, pointsPrefix+s+"#"+user, delta)
+	if err != nil {
+		// The change was not made, it can be tried again right away.
+		if cooldown > 0 {
+			req.Store.Delete(ctx, Namespace, cooldownKey)
+		}
+		resp.ReplyError(fmt.Errorf("failed to update karma: %s", err))
+		return
+	}
+	verb := "gained"
+	if delta < 0 {
+		verb = "lost"
+	}
+	resp.Reply(fmt.Sprintf("%s %s a point, now at %d", commands.Mention(user), verb, points))
+}
+
+func show(ctx context.Context, req *commands.Request, resp commands.Responder, who string) {
+	user := normalize(who)
+	points, err := get(ctx, req.Store, pointsPrefix+scope(req)+"#"+user)
+	if err != nil {
+		resp.ReplyError(fmt.Errorf("failed to get karma: %s", err))
+		return
+	}
+	resp.Reply(fmt.Sprintf("%s has %d karma", commands.Mention(user), points))
+}
+
+func top(ctx context.Context, req *commands.Request, resp commands.Responder, words []string) {
+	n := defaultTop
+	if len(words) > 1 {
+		var err error
+		if n, err = strconv.Atoi(words[1]); err != nil || n < 1 || n > maxTop {
+			resp.ReplyError(fmt.Errorf("expected a number between 1 and %d, got %q", maxTop, words[1]))
+			return
+		}
+	}
+	prefix := pointsPrefix + scope(req) + "#"
+	entries, err := req.Store.Scan(ctx, Namespace, prefix)
+	if err != nil {
+		resp.ReplyError(fmt.Errorf("failed to list karma: %s", err))
+		return
+	}
+	type score struct {
+		user   string
+		points int64
+	}
+	scores := make([]score, 0, len(entries))
+	for _, e := range entries {
+		points, err := strconv.ParseInt(string(e.Value), 10, 64)
+		if err != nil || points == 0 {
+			continue
+		}
+		scores = append(scores, score{user: strings.TrimPrefix(e.Key, prefix), points: points})
+	}
+	if len(scores) == 0 {
+		resp.Reply("nobody has karma in this channel yet, give some with `karma @user++`")
+		return
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].points > scores[j].points
+	})
+	if len(scores) > n {
+		scores = scores[:n]
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString("*Karma leaderboard*")
+	for i, s := range scores {
+		sb.WriteString(fmt.Sprintf("\n%d. %s `%+d`", i+1, commands.Mention(s.user), s.points))
+	}
+	resp.ReplyMessage(events.Message{Text: sb.String()})
+}
+
+func get(ctx context.Context, s store.Store, key string) (int64, error) {
+	value, found, err := s.Get(ctx, Namespace, key)
+	if err != nil || !found {
+		return 0, err
+	}
+	return strconv.ParseInt(string(value), 10, 64)
+}
+
+// scope returns the Slack source URL of the channel of req, e.g.
+// "https://acme.slack.com/messages/C0123/". The domain is taken from the
+// source of the command event.
+func scope(req *commands.Request) string {
+	domain := "unknown"
+	if req.Event.Context != nil {
+		if source := req.Event.Context.AsV02().Source.URL; source.Host != "" {
+			domain = strings.TrimSuffix(source.Host, ".slack.com")
+		}
+	}
+	source := events.Slack.SourceForChannel(domain, req.Channel)
+	return source.String()
+}
+
+// normalize turns the ways a user can be mentioned, "@alice", "alice" and
+// Slack's "<@U0123>" or "<@U0123|alice>", into the name karma is kept by.
+func normalize(who string) string {
+	who = strings.TrimSpace(who)
+	if strings.HasPrefix(who, "<@") && strings.HasSuffix(who, ">") {
+		who = strings.TrimSuffix(strings.TrimPrefix(who, "<@"), ">")
+		if i := strings.Index(who, "|"); i >= 0 {
+			who = who[:i]
+		}
+		return who
+	}
+	who = strings.TrimPrefix(who, "@")
+	if commands.IsUserID(who) {
+		return who
+	}
+	return strings.ToLower(who)
+}
@@ -0,0 +1,122 @@
+package karma
+
+import (
+	"context"
+	"errors"
+	"github.com/botless/commands/pkg/commands/commandstest"
+	"github.com/botless/commands/pkg/store"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// run runs karma as author with args and returns the reply.
+func run(s store.Store, cooldown time.Duration, author, args string) string {
+	return commandstest.Run(s, Command(cooldown), author, args)
+}
+
+func TestParseChange(t *testing.T) {
+	tests := []struct {
+		args  string
+		who   string
+		delta int64
+		ok    bool
+	}{
+		{"@alice++", "@alice", 1, true},
+		{"@alice--", "@alice", -1, true},
+		{"@alice ++", "@alice", 1, true},
+		{"@alice -- for breaking the build", "@alice", -1, true},
+		{"@alice++ thanks!", "@alice", 1, true},
+		{"<@U0123|alice>++ great review", "<@U0123|alice>", 1, true},
+		{"@alice", "", 0, false},
+		{"@alice thanks ++", "", 0, false},
+		{"++", "", 0, false},
+		{"", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			who, delta, ok := parseChange(strings.Fields(tt.args))
+			if who != tt.who || delta != tt.delta || ok != tt.ok {
+				t.Errorf("parseChange() = %q, %d, %t, want %q, %d, %t", who, delta, ok, tt.who, tt.delta, tt.ok)
+			}
+		})
+	}
+}
+
+func TestKarma(t *testing.T) {
+	s := store.NewMemory()
+	steps := []struct {
+		author string
+		args   string
+		want   string
+	}{
+		{"bob", "top", "nobody has karma in this channel yet, give some with `karma @user++`"},
+		{"bob", "@alice++ thanks!", "alice gained a point, now at 1"},
+		{"carol", "alice ++", "alice gained a point, now at 2"},
+		{"dave", "<@U0123> --", "<@U0123> lost a point, now at -1"},
+		{"alice", "@Alice++", "error: nice try, you can not change your own karma"},
+		{"bob", "@alice", "alice has 2 karma"},
+		{"bob", "@nobody", "nobody has 0 karma"},
+		{"bob", "top", "*Karma leaderboard*\n1. alice `+2`\n2. <@U0123> `-1`"},
+		{"bob", "top 1", "*Karma leaderboard*\n1. alice `+2`"},
+		{"bob", "top 0", "error: expected a number between 1 and 50, got \"0\""},
+		{"bob", "@alice thanks", "error: usage: `karma @user++ [reason] | karma @user-- [reason] | karma @user | karma top [n]`"},
+	}
+	for _, step := range steps {
+		if got := run(s, 0, step.author, step.args); got != step.want {
+			t.Errorf("%s: karma %s = %q, want %q", step.author, step.args, got, step.want)
+		}
+	}
+}
+
+func TestKarmaCooldown(t *testing.T) {
+	s := store.NewMemory()
+	if got, want := run(s, time.Hour, "bob", "@alice++"), "alice gained a point, now at 1"; got != want {
+		t.Fatalf("karma = %q, want %q", got, want)
+	}
+	if got, want := run(s, time.Hour, "bob", "@alice--"), "easy there, you can change the karma of alice once every 1h0m0s"; got != want {
+		t.Errorf("karma = %q, want %q", got, want)
+	}
+	// The cooldown is per pair of users.
+	if got, want := run(s, time.Hour, "carol", "@alice++"), "alice gained a point, now at 2"; got != want {
+		t.Errorf("karma = %q, want %q", got, want)
+	}
+}
+
+func TestKarmaCooldownConcurrent(t *testing.T) {
+	s := store.NewMemory()
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(s, time.Hour, "bob", "@alice++")
+		}()
+	}
+	wg.Wait()
+	if got, want := run(s, 0, "carol", "@alice"), "alice has 1 karma"; got != want {
+		t.Errorf("karma = %q, want %q", got, want)
+	}
+}
+
+// failingStore fails to change points.
+type failingStore struct {
+	store.Store
+}
+
+func (s failingStore) Incr(ctx context.Context, ns, key string, delta int64) (int64, error) {
+	return 0, errors.New("unavailable")
+}
+
+func TestKarmaCooldownFailed(t *testing.T) {
+	s := store.NewMemory()
+	if got, want := run(failingStore{s}, time.Hour, "bob", "@alice++"), "error: failed to update karma: unavailable"; got != want {
+		t.Fatalf("karma = %q, want %q", got, want)
+	}
+	// The failed change does not count against the cooldown.
+	if got, want := run(s, time.Hour, "bob", "@alice++"), "alice gained a point, now at 1"; got != want {
+		t.Errorf("karma = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"github.com/botless/commands/pkg/metrics"
+	"time"
+)
+
+// otherType is the type label of events no registered command handles.
+const otherType = "other"
+
+// Metrics records the throughput, latency and failures of Commands. A nil
+// *Metrics records nothing.
+type Metrics struct {
+	received       *metrics.CounterVec
+	ignored        *metrics.CounterVec
+	decodeFailures *metrics.CounterVec
+	duplicates     *metrics.CounterVec
+	denied         *metrics.CounterVec
+	limited        *metrics.CounterVec
+	sends          *metrics.CounterVec
+	handlerLatency *metrics.HistogramVec
+	sendLatency    *metrics.HistogramVec
+	inflight       *metrics.Gauge
+}
+
+// NewMetrics registers the command metrics with r.
+func NewMetrics(r *metrics.Registry) *Metrics {
+	return &Metrics{
+		received: r.NewCounterVec("botless_commands_received_total",
+			"Events received, by event type. Unknown types are counted as other.", "type"),
+		ignored: r.NewCounterVec("botless_commands_ignored_total",
+			"Events ignored because of the type filter or an unknown type, by event type. Unknown types are counted as other.", "type"),
+		decodeFailures: r.NewCounterVec("botless_commands_decode_failures_total",
+			"Events whose data could not be decoded as a command, by event type.", "type"),
+		denied: r.NewCounterVec("botless_commands_denied_total",
+			"Commands denied by the acl, by command.", "command"),
+		limited: r.NewCounterVec("botless_commands_rate_limited_total",
+			"Commands dropped by rate limits, by command.", "command"),
+		duplicates: r.NewCounterVec("botless_commands_duplicates_total",
+			"Redelivered events dropped by dedupe, by event type.", "type"),
+		sends: r.NewCounterVec("botless_commands_sends_total",
+			"Responses sent, by command and result.", "command", "result"),
+		handlerLatency: r.NewHistogramVec("botless_commands_handler_duration_seconds",
+			"Time spent handling a command, including sending responses.", nil, "command"),
+		sendLatency: r.NewHistogramVec("botless_commands_send_duration_seconds",
+			"Time spent sending a response, including retries.", nil, "command"),
+		inflight: r.NewGauge("botless_commands_inflight",
+			"Commands currently being handled."),
+	}
+}
+
+func (m *Metrics) receivedEvent(t string) {
+	if m != nil {
+		m.received.With(t).Inc()
+	}
+}
+
+func (m *Metrics) ignoredEvent(t string) {
+	if m != nil {
+		m.ignored.With(t).Inc()
+	}
+}
+
+func (m *Metrics) decodeFailure(t string) {
+	if m != nil {
+		m.decodeFailures.With(t).Inc()
+	}
+}
+
+func (m *Metrics) duplicateEvent(t string) {
+	if m != nil {
+		m.duplicates.With(t).Inc()
+	}
+}
+
+func (m *Metrics) deniedCommand(command string) {
+	if m != nil {
+		m.denied.With(command).Inc()
+	}
+}
+
+func (m *Metrics) rateLimited(command string) {
+	if m != nil {
+		m.limited.With(command).Inc()
+	}
+}
+
+// handling records a handler starting and returns a func to call when it
+// is done.
+func (m *Metrics) handling(command string) func() {
+	if m == nil {
+		return func() {}
+	}
+	start := time.Now()
+	m.inflight.Inc()
+	return func() {
+		m.inflight.Dec()
+		m.handlerLatency.With(command).ObserveSince(start)
+	}
+}
+
+func (m *Metrics) sent(command string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.sendLatency.With(command).ObserveSince(start)
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.sends.With(command, result).Inc()
+}
+
+// CommandCounts summarizes what a single command has done.
+type CommandCounts struct {
+	Handled       uint64 `json:"handled"`
+	SendSuccesses uint64 `json:"sendSuccesses"`
+	SendFailures  uint64 `json:"sendFailures"`
+}
+
+// Counts returns the counts recorded for the named command.
+func (m *Metrics) Counts(command string) CommandCounts {
+	if m == nil {
+		return CommandCounts{}
+	}
+	return CommandCounts{
+		Handled:       m.handlerLatency.Count(command),
+		SendSuccesses: uint64(m.sends.Value(command, "success")),
+		SendFailures:  uint64(m.sends.Value(command, "failure")),
+	}
+}
@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/botless/commands/pkg/logging"
+	"github.com/botless/events/pkg/events"
+	"strings"
+)
+
+// stage is one command of a pipeline.
+type stage struct {
+	cmd  *Command
+	args string
+}
+
+// errUnknownStage is returned by lookupStage when a stage does not name a
+// command.
+var errUnknownStage = errors.New("unknown command")
+
+// runPipeline runs cmd with the first of stages as its arguments, then feeds
+// the output of each stage to the next. The output of the last stage is sent
+// with resp. The pipeline stops at the first stage that fails or is not
+// allowed to run, naming it in the reply. When a stage does not name a
+// command, req is not a pipeline and cmd is run with its arguments as typed.
+func (c *Commands) runPipeline(ctx context.Context, cmd *Command, req *Request, stages []string, resp Responder) {
+	logger := logging.FromContext(ctx)
+	pipeline := []stage{{cmd: cmd, args: stages[0]}}
+	for i, s := range stages[1:] {
+		name := strings.Fields(s)[0]
+		st, err := c.lookupStage(ctx, req.Channel, name)
+		if err == errUnknownStage {
+			logger.Debugf("stage %d %q is not a command, not running a pipeline", i+2, name)
+			c.run(ctx, cmd, req, resp)
+			return
+		}
+		if err != nil {
+			resp.ReplyError(fmt.Errorf("pipeline stage %d `%s`: %s", i+2, name, err))
+			return
+		}
+		st.args = strings.TrimSpace(st.args + " " + strings.TrimSpace(s[len(name):]))
+		pipeline = append(pipeline, st)
+	}
+
+	output := ""
+	for i, st := range pipeline {
+		sreq := *req
+		sreq.Cmd = st.cmd.Name
+		sreq.Args = strings.TrimSpace(st.args + " " + output)
+		sreq.Params = nil
+		sctx := logging.WithLogger(ctx, logger.With("stage", st.cmd.Name))
+		out := &captureResponder{}
+		ran := c.run(sctx, st.cmd, &sreq, out)
+		switch {
+		case out.err != nil:
+			resp.ReplyError(fmt.Errorf("pipeline stage %d `%s` failed: %s", i+1, st.cmd.Name, out.err))
+			return
+		case !ran && out.text() == "":
+			resp.ReplyError(fmt.Errorf("pipeline stage %d `%s` did not run", i+1, st.cmd.Name))
+			return
+		case !ran:
+			resp.ReplyError(fmt.Errorf("pipeline stage %d `%s`: %s", i+1, st.cmd.Name, out.text()))
+			return
+		}
+		output = out.text()
+	}
+	resp.Reply(output)
+}
+
+// lookupStage finds the command a pipeline stage names, resolving static and
+// user defined aliases. The command must be enabled and selected by the
+// filter, as the whole pipeline runs in this process.
+func (c *Commands) lookupStage(ctx context.Context, channel, name string) (stage, error) {
+	st := stage{}
+	if cmd, found := c.registry().Lookup(name); found {
+		st.cmd = cmd
+	} else if cmd, alias, found := c.lookupAlias(ctx, channel, name); found {
+		st.cmd = cmd
+		st.args = alias.Args
+	} else {
+		return st, errUnknownStage
+	}
+	if !c.registry().Enabled(st.cmd) {
+		return st, fmt.Errorf("command is disabled")
+	}
+	if !c.Filter.Match(st.cmd.Type) {
+		return st, fmt.Errorf("command is not handled here")
+	}
+	return st, nil
+}
+
+// captureResponder is a Responder that keeps the replies of a pipeline stage
+// as the input of the next one.
+type captureResponder struct {
+	replies []string
+	err     error
+}
+
+var _ Responder = (*captureResponder)(nil)
+
+func (r *captureResponder) Reply(text string) {
+	r.replies = append(r.replies, text)
+}
+
+func (r *captureResponder) ReplyMessage(msg events.Message) {
+	r.Reply(msg.Text)
+}
+
+func (r *captureResponder) ReplyError(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// text returns the replies joined by new lines.
+func (r *captureResponder) text() string {
+	return strings.Join(r.replies, "\n")
+}
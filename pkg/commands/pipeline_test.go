@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"testing"
+)
+
+func TestPipelines(t *testing.T) {
+	c := &Commands{
+		Registry:  testRegistry(t),
+		Pipelines: true,
+	}
+	for _, tt := range []struct {
+		text, want string
+	}{
+		{"echo hi | caps", "HI"},
+		{"echo hi | shout | echo >", "> HI"},
+		{"echo 'a | b' | caps", "'A | B'"},
+		{`echo a \| b`, "a | b"},
+		// Arguments that are not a pipeline are passed on as typed.
+		{"echo a|b", "a|b"},
+		{"echo a | caps | b", "a | caps | b"},
+		{"echo a |", "a |"},
+	} {
+		if got := reply(t, c, "c1", tt.text); got != tt.want {
+			t.Errorf("%q replied %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	c = &Commands{
+		Registry:  testRegistry(t),
+		Filter:    mustFilter(t, CommandType("echo")),
+		Pipelines: true,
+	}
+	want := "echo: pipeline stage 2 `caps`: command is not handled here"
+	if got := reply(t, c, "c1", "echo hi | caps"); got != want {
+		t.Errorf("pipeline through another service replied %q, want %q", got, want)
+	}
+
+	c = &Commands{Registry: testRegistry(t)}
+	if got, want := reply(t, c, "c1", "echo hi | caps"), "hi | caps"; got != want {
+		t.Errorf("without Pipelines replied %q, want %q", got, want)
+	}
+}
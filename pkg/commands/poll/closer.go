@@ -0,0 +1,98 @@
+package poll
+
+import (
+	"context"
+	"github.com/botless/commands/pkg/commands"
+	"github.com/botless/commands/pkg/logging"
+	"github.com/botless/commands/pkg/store"
+	"github.com/botless/events/pkg/events"
+	"time"
+)
+
+// Closer closes polls whose time is up and posts their results. Every
+// replica may run one: a poll is claimed in the Store before it is closed, so
+// as long as the replicas share the Store the results are posted once. The
+// votes are only removed once the results are posted.
+type Closer struct {
+	Store  store.Store
+	Sender *commands.Sender
+
+	// Interval is how often expired polls are looked for. Defaults to 10s.
+	Interval time.Duration
+	// MaxAttempts is the number of times posting the results is tried, once
+	// per Interval, before the poll is closed without them. Defaults to 5.
+	MaxAttempts int
+
+	// Logger defaults to logging.Default().
+	Logger *logging.Logger
+}
+
+// Run closes expired polls until ctx is done.
+func (c *Closer) Run(ctx context.Context) {
+	c.deliverer().Run(ctx)
+}
+
+func (c *Closer) closeExpired(ctx context.Context) {
+	c.deliverer().DeliverDue(ctx)
+}
+
+func (c *Closer) deliverer() *commands.Deliverer {
+	return &commands.Deliverer{
+		Store:       c.Store,
+		Sender:      c.Sender,
+		Namespace:   Namespace,
+		Name:        "poll",
+		Due:         c.expired,
+		Take:        c.take,
+		Interval:    c.Interval,
+		MaxAttempts: c.MaxAttempts,
+		Logger:      c.Logger,
+	}
+}
+
+func (c *Closer) expired(ctx context.Context) ([]string, error) {
+	all, err := polls(ctx, c.Store)
+	if err != nil {
+		return nil, err
+	}
+	t := now()
+	var ids []string
+	for _, p := range all {
+		if !p.Closed && !p.open(t) {
+			ids = append(ids, p.ID)
+		}
+	}
+	return ids, nil
+}
+
+// take counts the votes of the poll id. It is closed once its results are
+// posted, or when posting them failed MaxAttempts times.
+func (c *Closer) take(ctx context.Context, id string) (*commands.Delivery, error) {
+	// Load again, the poll may have been closed since it was listed.
+	p, found, err := load(ctx, c.Store, id)
+	if err != nil || !found || p.Closed {
+		return nil, err
+	}
+	tally, err := count(ctx, c.Store, p)
+	if err != nil {
+		return nil, err
+	}
+	final := p
+	final.Closed = true
+	done := func(ctx context.Context) error {
+		_, err := finish(ctx, c.Store, p, tally)
+		return err
+	}
+	return &commands.Delivery{
+		Message:  events.Message{Channel: p.Channel, Text: render(final, tally)},
+		Attempts: p.Attempts,
+		Sent:     done,
+		Failed: func(ctx context.Context, attempts int, retry bool) error {
+			if !retry {
+				return done(ctx)
+			}
+			p.Attempts = attempts
+			return save(ctx, c.Store, p)
+		},
+	}, nil
+}
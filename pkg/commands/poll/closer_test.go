@@ -0,0 +1,115 @@
+package poll
+
+import (
+	"context"
+	"github.com/botless/commands/pkg/commands"
+	"github.com/botless/commands/pkg/commands/commandstest"
+	"github.com/botless/commands/pkg/logging"
+	"github.com/botless/commands/pkg/store"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newCloser(s store.Store, ce *commandstest.Client) *Closer {
+	return &Closer{
+		Store:       s,
+		Sender:      &commands.Sender{Ce: ce},
+		MaxAttempts: 2,
+		Logger:      logging.New(ioutil.Discard, logging.Error, false),
+	}
+}
+
+// expiredPoll creates poll 1 closing after an hour with a vote for Tacos and
+// moves now past it. The returned func restores now.
+func expiredPoll(s store.Store) func() {
+	start := time.Date(2019, time.January, 2, 10, 0, 0, 0, time.UTC)
+	clock := commandstest.NewClock(start)
+	now = clock.Now
+	commandstest.Run(s, Command(), "U01", `--for=1h "Lunch?" "Tacos" "Pizza"`)
+	commandstest.Run(s, VoteCommand(), "U02", "1 1")
+	clock.Set(start.Add(time.Hour))
+	return func() { now = time.Now }
+}
+
+const results = "*Poll 1: Lunch?* (final results, 1 vote)\n1. Tacos `██████████` 1 (100%)\n2. Pizza `░░░░░░░░░░` 0 (0%)"
+
+func votes(t *testing.T, s store.Store) int {
+	t.Helper()
+	entries, err := s.Scan(context.Background(), Namespace, votePrefix)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	return len(entries)
+}
+
+func TestCloserPostsResults(t *testing.T) {
+	s := store.NewMemory()
+	defer expiredPoll(s)()
+
+	// Replicas sharing the store post the results once.
+	ce := &commandstest.Client{}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		closer := newCloser(s, ce)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			closer.closeExpired(context.Background())
+		}()
+	}
+	wg.Wait()
+	newCloser(s, ce).closeExpired(context.Background())
+
+	if got := ce.Messages(); len(got) != 1 || got[0] != results {
+		t.Errorf("sent %q, want the results once", got)
+	}
+	if got := commandstest.Run(s, Command(), "U03", "show 1"); got != results {
+		t.Errorf("show = %q, want %q", got, results)
+	}
+	if n := votes(t, s); n != 0 {
+		t.Errorf("%d votes left after closing", n)
+	}
+}
+
+func TestCloserRetries(t *testing.T) {
+	s := store.NewMemory()
+	defer expiredPoll(s)()
+	ce := &commandstest.Client{Failures: 1}
+	closer := newCloser(s, ce)
+
+	// The votes are kept until the results are posted.
+	closer.closeExpired(context.Background())
+	if p, _, err := load(context.Background(), s, "1"); err != nil || p.Closed || p.Attempts != 1 {
+		t.Fatalf("load() = %+v, %v, want open with 1 attempt", p, err)
+	}
+	if n := votes(t, s); n != 1 {
+		t.Fatalf("%d votes after a failed post, want 1", n)
+	}
+
+	closer.closeExpired(context.Background())
+	if got := ce.Messages(); len(got) != 1 || got[0] != results {
+		t.Errorf("sent %q, want the results once", got)
+	}
+	if p, _, err := load(context.Background(), s, "1"); err != nil || !p.Closed {
+		t.Errorf("load() = %+v, %v, want closed", p, err)
+	}
+}
+
+func TestCloserGivesUp(t *testing.T) {
+	s := store.NewMemory()
+	defer expiredPoll(s)()
+	ce := &commandstest.Client{Failures: 2}
+	closer := newCloser(s, ce)
+
+	closer.closeExpired(context.Background())
+	closer.closeExpired(context.Background())
+	if got := ce.Messages(); len(got) != 0 {
+		t.Errorf("sent %q, want nothing", got)
+	}
+	// Closed without posting, the results can still be shown.
+	if got := commandstest.Run(s, Command(), "U03", "show 1"); got != results {
+		t.Errorf("show = %q, want %q", got, results)
+	}
+}
@@ -0,0 +1,495 @@
+// Package poll implements the poll and vote commands. Polls are kept in the
+// Store of Commands, each author has one vote per poll which they may change,
+// and polls can close themselves after a while.
+package poll
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/botless/commands/pkg/commands"
+	"github.com/botless/commands/pkg/commands/args"
+	"github.com/botless/commands/pkg/ratelimit"
+	"github.com/botless/commands/pkg/store"
+	"github.com/botless/events/pkg/events"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Namespace is the store namespace holding polls.
+	Namespace = "poll"
+
+	pollPrefix  = "poll/"
+	votePrefix  = "vote/"
+	sequenceKey = "sequence"
+
+	minOptions = 2
+	maxOptions = 10
+	// maxOpen bounds the open polls of a channel.
+	maxOpen = 20
+	// closedTTL is how long closed polls can still be shown.
+	closedTTL = 7 * 24 * time.Hour
+	// maxDuration bounds the auto close duration.
+	maxDuration = 30 * 24 * time.Hour
+
+	barWidth = 10
+)
+
+// Poll is a question with numbered options.
+type Poll struct {
+	ID       string    `json:"id"`
+	Channel  string    `json:"channel"`
+	Author   string    `json:"author"`
+	Question string    `json:"question"`
+	Options  []string  `json:"options"`
+	Created  time.Time `json:"created"`
+	// Closes is when the poll closes itself, zero if it does not.
+	Closes time.Time `json:"closes,omitempty"`
+	Closed bool      `json:"closed,omitempty"`
+	// Results are the final votes per option, set when closed.
+	Results []int `json:"results,omitempty"`
+	// Attempts counts the failed posts of the results once expired.
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// open reports whether p still takes votes at t.
+func (p Poll) open(t time.Time) bool {
+	return !p.Closed && (p.Closes.IsZero() || t.Before(p.Closes))
+}
+
+// now is the current time, set by the tests to expire polls without waiting
+// for them.
+var now = time.Now
+
+var newPoll = &args.Schema{
+	Flags: []args.Flag{
+		{Name: "for", Kind: args.Duration, Usage: "close the poll automatically after this long, e.g. 1h"},
+	},
+	Args: []args.Arg{
+		{Name: "question", Required: true, Usage: "what to vote on, quoted"},
+		{Name: "options", Required: true, Variadic: true, Usage: "the choices, each quoted"},
+	},
+}
+
+// Command returns the poll command.
+func Command() commands.Command {
+	return commands.Command{
+		Name:        "poll",
+		Description: "Starts a poll, shows its tally or closes it.",
+		Usage:       `poll [--for=duration] "<question>" "<option>"... | poll show <id> | poll close <id> | poll list`,
+		Examples: []string{
+			`poll "Lunch?" "Tacos" "Pizza" "Sushi"`,
+			`poll --for=1h "Retro topic?" "Deploys" "On call"`,
+			"poll show 3",
+			"poll close 3",
+		},
+		Handler: func(ctx context.Context, req *commands.Request, resp commands.Responder) {
+			if req.Store == nil {
+				resp.ReplyError(fmt.Errorf("polls are not available, no store is configured"))
+				return
+			}
+			fields := strings.Fields(req.Args)
+			if len(fields) == 0 {
+				fields = []string{"list"}
+			}
+			switch strings.ToLower(fields[0]) {
+			case "list", "ls":
+				list(ctx, req, resp)
+				return
+			case "show", "results":
+				if len(fields) != 2 {
+					resp.ReplyError(fmt.Errorf("usage: `poll show <id>`"))
+					return
+				}
+				show(ctx, req, resp, fields[1])
+				return
+			case "close":
+				if len(fields) != 2 {
+					resp.ReplyError(fmt.Errorf("usage: `poll close <id>`"))
+					return
+				}
+				closePoll(ctx, req, resp, fields[1])
+				return
+			}
+			params, err := newPoll.Parse(req.Args)
+			if err != nil {
+				resp.ReplyError(err)
+				return
+			}
+			create(ctx, req, resp, params)
+		},
+	}
+}
+
+// VoteCommand returns the vote command. Authors are rate limited so a single
+// author can not flood a poll with vote changes.
+func VoteCommand() commands.Command {
+	return commands.Command{
+		Name:        "vote",
+		Description: "Votes for an option of a poll, voting again changes the vote.",
+		Examples:    []string{"vote 3 2"},
+		Args: &args.Schema{
+			Args: []args.Arg{
+				{Name: "poll", Kind: args.Int, Required: true, Usage: "the poll id"},
+				{Name: "option", Kind: args.Int, Required: true, Usage: "the option number"},
+			},
+		},
+		RateLimits: &commands.RateLimits{
+			PerAuthor: ratelimit.Limit{Events: 10, Per: time.Minute},
+		},
+		Handler: func(ctx context.Context, req *commands.Request, resp commands.Responder) {
+			if req.Store == nil {
+				resp.ReplyError(fmt.Errorf("polls are not available, no store is configured"))
+				return
+			}
+			vote(ctx, req, resp, strconv.Itoa(req.Params.Int("poll")), req.Params.Int("option"))
+		},
+	}
+}
+
+func create(ctx context.Context, req *commands.Request, resp commands.Responder, params *args.Values) {
+	words := params.Positional()
+	options := words[1:]
+	if len(options) < minOptions || len(options) > maxOptions {
+		resp.ReplyError(fmt.Errorf("a poll needs %d to %d options, got %d. Quote options with spaces", minOptions, maxOptions, len(options)))
+		return
+	}
+	p := Poll{
+		Channel:  req.Channel,
+		Author:   req.Author,
+		Question: words[0],
+		Options:  options,
+		Created:  now(),
+	}
+	if d := params.Duration("for"); d != 0 {
+		if d < time.Minute || d > maxDuration {
+			resp.ReplyError(fmt.Errorf("--for must be between 1m and %s", maxDuration))
+			return
+		}
+		p.Closes = p.Created.Add(d)
+	}
+
+	polls, err := channelPolls(ctx, req.Store, req.Channel)
+	if err != nil {
+		resp.ReplyError(fmt.Errorf("failed to list polls: %s", err))
+		return
+	}
+	open := 0
+	for _, o := range polls {
+		if o.open(p.Created) {
+			open++
+		}
+	}
+	if open >= maxOpen {
+		resp.ReplyError(fmt.Errorf("this channel already has %d open polls, close some first", open))
+		return
+	}
+
+	seq, err := req.Store.Incr(ctx, Namespace, sequenceKey, 1)
+	if err != nil {
+		resp.ReplyError(fmt.Errorf("failed to save poll: %s", err))
+		return
+	}
+	p.ID = strconv.FormatInt(seq, 10)
+	if err := save(ctx, req.Store, p); err != nil {
+		resp.ReplyError(fmt.Errorf("failed to save poll: %s", err))
+		return
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("*Poll %s: %s*", p.ID, p.Question))
+	for i, o := range p.Options {
+		sb.WriteString(fmt.Sprintf("\n%d. %s", i+1, o))
+	}
+	sb.WriteString(fmt.Sprintf("\nVote with `vote %s <n>`.", p.ID))
+	if !p.Closes.IsZero() {
+		sb.WriteString(fmt.Sprintf(" Closes at %s.", p.Closes.UTC().Format("Mon Jan 2 15:04 MST")))
+	}
+	resp.ReplyMessage(events.Message{Text: sb.String()})
+}
+
+func vote(ctx context.Context, req *commands.Request, resp commands.Responder, id string, option int) {
+	p, found, err := load(ctx, req.Store, id)
+	if err != nil {
+		resp.ReplyError(fmt.Errorf("failed to load poll: %s", err))
+		return
+	}
+	if !found || p.Channel != req.Channel {
+		resp.ReplyError(fmt.Errorf("no poll %s in this channel", id))
+		return
+	}
+	if !p.open(now()) {
+		resp.ReplyError(fmt.Errorf("poll %s is closed", id))
+		return
+	}
+	if option < 1 || option > len(p.Options) {
+		resp.ReplyError(fmt.Errorf("poll %s has options 1 to %d", id, len(p.Options)))
+		return
+	}
+
+	key := voteKey(id, req.Author)
+	previous, voted, err := req.Store.Get(ctx, Namespace, key)
+	if err != nil {
+		resp.ReplyError(fmt.Errorf("failed to load vote: %s", err))
+		return
+	}
+	if err := req.Store.Set(ctx, Namespace, key, []byte(strconv.Itoa(option)), 0); err != nil {
+		resp.ReplyError(fmt.Errorf("failed to save vote: %s", err))
+		return
+	}
+	// The poll may have been closed while voting, after its votes were
+	// removed. The vote must not outlive it then.
+	if p, _, err = load(ctx, req.Store, id); err != nil {
+		resp.ReplyError(fmt.Errorf("failed to load poll: %s", err))
+		return
+	}
+	if p.Closed {
+		if err := req.Store.Delete(ctx, Namespace, key); err != nil {
+			resp.ReplyError(fmt.Errorf("failed to remove vote: %s", err))
+			return
+		}
+		resp.ReplyError(fmt.Errorf("poll %s is closed", id))
+		return
+	}
+	tally, err := count(ctx, req.Store, p)
+	if err != nil {
+		resp.ReplyError(fmt.Errorf("failed to count votes: %s", err))
+		return
+	}
+	verb := "voted for"
+	if voted && string(previous) != strconv.Itoa(option) {
+		verb = "changed their vote to"
+	}
+	resp.ReplyMessage(events.Message{
+		Text: fmt.Sprintf("%s %s %q\n%s", commands.Mention(req.Author), verb, p.Options[option-1], render(p, tally)),
+	})
+}
+
+func show(ctx context.Context, req *commands.Request, resp commands.Responder, id string) {
+	p, found, err := load(ctx, req.Store, id)
+	if err != nil {
+		resp.ReplyError(fmt.Errorf("failed to load poll: %s", err))
+		return
+	}
+	if !found || p.Channel != req.Channel {
+		resp.ReplyError(fmt.Errorf("no poll %s in this channel", id))
+		return
+	}
+	tally := p.Results
+	if !p.Closed {
+		if tally, err = count(ctx, req.Store, p); err != nil {
+			resp.ReplyError(fmt.Errorf("failed to count votes: %s", err))
+			return
+		}
+	}
+	resp.ReplyMessage(events.Message{Text: render(p, tally)})
+}
+
+func closePoll(ctx context.Context, req *commands.Request, resp commands.Responder, id string) {
+	p, found, err := load(ctx, req.Store, id)
+	if err != nil {
+		resp.ReplyError(fmt.Errorf("failed to load poll: %s", err))
+		return
+	}
+	if !found || p.Channel != req.Channel {
+		resp.ReplyError(fmt.Errorf("no poll %s in this channel", id))
+		return
+	}
+	if p.Author != req.Author {
+		resp.ReplyError(fmt.Errorf("only %s can close poll %s", commands.Mention(p.Author), id))
+		return
+	}
+	if p.Closed {
+		resp.ReplyError(fmt.Errorf("poll %s is already closed", id))
+		return
+	}
+	// Claim the poll as the Closer does, so its results are posted once.
+	claimed, err := commands.Claim(ctx, req.Store, Namespace, id)
+	if err != nil {
+		resp.ReplyError(fmt.Errorf("failed to claim poll: %s", err))
+		return
+	}
+	if !claimed {
+		resp.ReplyError(fmt.Errorf("poll %s is being closed", id))
+		return
+	}
+	defer commands.Release(ctx, req.Store, Namespace, id)
+	// Load again, the poll may have been closed before it was claimed.
+	if p, _, err = load(ctx, req.Store, id); err != nil {
+		resp.ReplyError(fmt.Errorf("failed to load poll: %s", err))
+		return
+	}
+	if p.Closed {
+		resp.ReplyError(fmt.Errorf("poll %s is already closed", id))
+		return
+	}
+	tally, err := count(ctx, req.Store, p)
+	if err != nil {
+		resp.ReplyError(fmt.Errorf("failed to count votes: %s", err))
+		return
+	}
+	if p, err = finish(ctx, req.Store, p, tally); err != nil {
+		resp.ReplyError(fmt.Errorf("failed to close poll: %s", err))
+		return
+	}
+	resp.ReplyMessage(events.Message{Text: render(p, p.Results)})
+}
+
+func list(ctx context.Context, req *commands.Request, resp commands.Responder) {
+	polls, err := channelPolls(ctx, req.Store, req.Channel)
+	if err != nil {
+		resp.ReplyError(fmt.Errorf("failed to list polls: %s", err))
+		return
+	}
+	t := now()
+	sb := strings.Builder{}
+	for _, p := range polls {
+		if p.open(t) {
+			sb.WriteString(fmt.Sprintf("\n• `%s` %s", p.ID, p.Question))
+		}
+	}
+	if sb.Len() == 0 {
+		resp.Reply("no open polls in this channel, start one with `poll \"<question>\" \"<option>\"...`")
+		return
+	}
+	resp.Reply("Open polls:" + sb.String())
+}
+
+// finish closes p with tally as its results. The votes are removed and the
+// poll is kept for closedTTL so the results can be shown.
+func finish(ctx context.Context, s store.Store, p Poll, tally []int) (Poll, error) {
+	p.Closed, p.Results = true, tally
+	data, err := json.Marshal(p)
+	if err != nil {
+		return p, err
+	}
+	if err := s.Set(ctx, Namespace, pollPrefix+p.ID, data, closedTTL); err != nil {
+		return p, err
+	}
+	votes, err := s.Scan(ctx, Namespace, votePrefix+p.ID+"/")
+	if err != nil {
+		return p, err
+	}
+	for _, v := range votes {
+		if err := s.Delete(ctx, Namespace, v.Key); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// render formats the tally of p as a bar chart, with lines like
+// "1. Tacos ██████░░░░ 3 (60%)".
+func render(p Poll, tally []int) string {
+	total := 0
+	for _, n := range tally {
+		total += n
+	}
+	sb := strings.Builder{}
+	state := "open"
+	if p.Closed {
+		state = "final results"
+	} else if !p.open(now()) {
+		state = "closed"
+	}
+	sb.WriteString(fmt.Sprintf("*Poll %s: %s* (%s, %d %s)", p.ID, p.Question, state, total, plural(total, "vote")))
+	for i, o := range p.Options {
+		n := 0
+		if i < len(tally) {
+			n = tally[i]
+		}
+		filled, percent := 0, 0
+		if total > 0 {
+			filled = (n*barWidth + total/2) / total
+			percent = (n*100 + total/2) / total
+		}
+		bar := strings.Repeat("█", filled) + strings.Repeat("░", barWidth-filled)
+		sb.WriteString(fmt.Sprintf("\n%d. %s `%s` %d (%d%%)", i+1, o, bar, n, percent))
+	}
+	return sb.String()
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
+
+// count tallies the votes of p per option.
+func count(ctx context.Context, s store.Store, p Poll) ([]int, error) {
+	votes, err := s.Scan(ctx, Namespace, votePrefix+p.ID+"/")
+	if err != nil {
+		return nil, err
+	}
+	tally := make([]int, len(p.Options))
+	for _, v := range votes {
+		option, err := strconv.Atoi(string(v.Value))
+		if err != nil || option < 1 || option > len(tally) {
+			continue
+		}
+		tally[option-1]++
+	}
+	return tally, nil
+}
+
+func voteKey(id, author string) string {
+	return votePrefix + id + "/" + author
+}
+
+// polls returns all stored polls, oldest first.
+func polls(ctx context.Context, s store.Store) ([]Poll, error) {
+	entries, err := s.Scan(ctx, Namespace, pollPrefix)
+	if err != nil {
+		return nil, err
+	}
+	all := make([]Poll, 0, len(entries))
+	for _, e := range entries {
+		p := Poll{}
+		if err := json.Unmarshal(e.Value, &p); err != nil {
+			continue
+		}
+		all = append(all, p)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Created.Before(all[j].Created)
+	})
+	return all, nil
+}
+
+func channelPolls(ctx context.Context, s store.Store, channel string) ([]Poll, error) {
+	all, err := polls(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	var in []Poll
+	for _, p := range all {
+		if p.Channel == channel {
+			in = append(in, p)
+		}
+	}
+	return in, nil
+}
+
+func load(ctx context.Context, s store.Store, id string) (Poll, bool, error) {
+	p := Poll{}
+	data, found, err := s.Get(ctx, Namespace, pollPrefix+id)
+	if err != nil || !found {
+		return p, false, err
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, false, err
+	}
+	return p, true, nil
+}
+
+func save(ctx context.Context, s store.Store, p Poll) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return s.Set(ctx, Namespace, pollPrefix+p.ID, data, 0)
+}
@@ -0,0 +1,130 @@
+package poll
+
+import (
+	"context"
+	"github.com/botless/commands/pkg/commands"
+	"github.com/botless/commands/pkg/commands/commandstest"
+	"github.com/botless/commands/pkg/store"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPoll(t *testing.T) {
+	start := time.Date(2019, time.January, 2, 10, 0, 0, 0, time.UTC)
+	clock := commandstest.NewClock(start)
+	now = clock.Now
+	defer func() { now = time.Now }()
+	s := store.NewMemory()
+	poll, vote := Command(), VoteCommand()
+
+	steps := []struct {
+		cmd    commands.Command
+		author string
+		args   string
+		want   string
+	}{
+		{poll, "U01", "", "no open polls in this channel, start one with `poll \"<question>\" \"<option>\"...`"},
+		{poll, "U01", `"Lunch?" "Tacos"`, "error: a poll needs 2 to 10 options, got 1. Quote options with spaces"},
+		{poll, "U01", `--for=30s "Lunch?" "Tacos" "Pizza"`, "error: --for must be between 1m and 720h0m0s"},
+		{poll, "U01", `--for=1h "Lunch?" "Tacos" "Pizza"`, "*Poll 1: Lunch?*\n1. Tacos\n2. Pizza\nVote with `vote 1 <n>`. Closes at Wed Jan 2 11:00 UTC."},
+		{poll, "U01", "list", "Open polls:\n• `1` Lunch?"},
+		{vote, "U02", "1 1", "<@U02> voted for \"Tacos\"\n*Poll 1: Lunch?* (open, 1 vote)\n1. Tacos `██████████` 1 (100%)\n2. Pizza `░░░░░░░░░░` 0 (0%)"},
+		{vote, "U03", "1 2", "<@U03> voted for \"Pizza\"\n*Poll 1: Lunch?* (open, 2 votes)\n1. Tacos `█████░░░░░` 1 (50%)\n2. Pizza `█████░░░░░` 1 (50%)"},
+		{vote, "U02", "1 2", "<@U02> changed their vote to \"Pizza\"\n*Poll 1: Lunch?* (open, 2 votes)\n1. Tacos `░░░░░░░░░░` 0 (0%)\n2. Pizza `██████████` 2 (100%)"},
+		{vote, "U02", "1 3", "error: poll 1 has options 1 to 2"},
+		{vote, "U02", "2 1", "error: no poll 2 in this channel"},
+		{poll, "U02", "close 1", "error: only <@U01> can close poll 1"},
+		{poll, "U01", "close 1", "*Poll 1: Lunch?* (final results, 2 votes)\n1. Tacos `░░░░░░░░░░` 0 (0%)\n2. Pizza `██████████` 2 (100%)"},
+		{poll, "U01", "close 1", "error: poll 1 is already closed"},
+		{vote, "U03", "1 1", "error: poll 1 is closed"},
+		{poll, "U01", "show 1", "*Poll 1: Lunch?* (final results, 2 votes)\n1. Tacos `░░░░░░░░░░` 0 (0%)\n2. Pizza `██████████` 2 (100%)"},
+	}
+	for _, step := range steps {
+		if got := commandstest.Run(s, step.cmd, step.author, step.args); got != step.want {
+			t.Errorf("%s %s: got %q, want %q", step.cmd.Name, step.args, got, step.want)
+		}
+	}
+
+	// Closing removed the votes.
+	votes, err := s.Scan(context.Background(), Namespace, votePrefix)
+	if err != nil || len(votes) != 0 {
+		t.Errorf("votes = %v, %v, want none", votes, err)
+	}
+}
+
+func TestPollExpires(t *testing.T) {
+	start := time.Date(2019, time.January, 2, 10, 0, 0, 0, time.UTC)
+	clock := commandstest.NewClock(start)
+	now = clock.Now
+	defer func() { now = time.Now }()
+	s := store.NewMemory()
+	commandstest.Run(s, Command(), "U01", `--for=1h "Lunch?" "Tacos" "Pizza"`)
+	commandstest.Run(s, VoteCommand(), "U02", "1 1")
+
+	clock.Set(start.Add(time.Hour))
+	if got, want := commandstest.Run(s, VoteCommand(), "U03", "1 2"), "error: poll 1 is closed"; got != want {
+		t.Errorf("vote = %q, want %q", got, want)
+	}
+	// Until the Closer posts the results they can still be shown.
+	want := "*Poll 1: Lunch?* (closed, 1 vote)\n1. Tacos `██████████` 1 (100%)\n2. Pizza `░░░░░░░░░░` 0 (0%)"
+	if got := commandstest.Run(s, Command(), "U03", "show 1"); got != want {
+		t.Errorf("show = %q, want %q", got, want)
+	}
+	if got, want := commandstest.Run(s, Command(), "U03", "list"), "no open polls in this channel, start one with `poll \"<question>\" \"<option>\"...`"; got != want {
+		t.Errorf("list = %q, want %q", got, want)
+	}
+}
+
+func TestPollCloseClaimed(t *testing.T) {
+	s := store.NewMemory()
+	commandstest.Run(s, Command(), "U01", `"Lunch?" "Tacos" "Pizza"`)
+
+	// The poll is being closed by a Closer.
+	if claimed, err := commands.Claim(context.Background(), s, Namespace, "1"); !claimed || err != nil {
+		t.Fatalf("Claim() = %t, %v", claimed, err)
+	}
+	if got, want := commandstest.Run(s, Command(), "U01", "close 1"), "error: poll 1 is being closed"; got != want {
+		t.Errorf("close = %q, want %q", got, want)
+	}
+	if err := commands.Release(context.Background(), s, Namespace, "1"); err != nil {
+		t.Fatal(err)
+	}
+	want := "*Poll 1: Lunch?* (final results, 0 votes)\n1. Tacos `░░░░░░░░░░` 0 (0%)\n2. Pizza `░░░░░░░░░░` 0 (0%)"
+	if got := commandstest.Run(s, Command(), "U01", "close 1"); got != want {
+		t.Errorf("close = %q, want %q", got, want)
+	}
+}
+
+// closingStore closes poll 1 right before a vote is written, after the vote
+// found it open.
+type closingStore struct {
+	store.Store
+}
+
+func (s closingStore) Set(ctx context.Context, ns, key string, value []byte, ttl time.Duration) error {
+	if strings.HasPrefix(key, votePrefix) {
+		p, _, err := load(ctx, s.Store, "1")
+		if err != nil {
+			return err
+		}
+		if _, err := finish(ctx, s.Store, p, make([]int, len(p.Options))); err != nil {
+			return err
+		}
+	}
+	return s.Store.Set(ctx, ns, key, value, ttl)
+}
+
+func TestPollVoteWhileClosing(t *testing.T) {
+	s := store.NewMemory()
+	commandstest.Run(s, Command(), "U01", `"Lunch?" "Tacos" "Pizza"`)
+
+	if got, want := commandstest.Run(closingStore{s}, VoteCommand(), "U02", "1 1"), "error: poll 1 is closed"; got != want {
+		t.Errorf("vote = %q, want %q", got, want)
+	}
+	// The vote does not outlive the poll.
+	votes, err := s.Scan(context.Background(), Namespace, votePrefix)
+	if err != nil || len(votes) != 0 {
+		t.Errorf("votes = %v, %v, want none", votes, err)
+	}
+}
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"context"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+)
+
+const (
+	defaultWorkers   = 16
+	defaultQueueSize = 100
+)
+
+// pool is a fixed set of workers handling events from a bounded queue.
+type pool struct {
+	queue chan cloudevents.Event
+}
+
+func newPool(workers, size int, fn func(cloudevents.Event)) *pool {
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	p := &pool{
+		queue: make(chan cloudevents.Event, size),
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for event := range p.queue {
+				fn(event)
+			}
+		}()
+	}
+	return p
+}
+
+// offer queues event without blocking. It returns false if the queue is full.
+func (p *pool) offer(event cloudevents.Event) bool {
+	select {
+	case p.queue <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// startPool lazily starts the worker pool of c.
+func (c *Commands) startPool() *pool {
+	c.poolOnce.Do(func() {
+		c.pool = newPool(c.Workers, c.QueueSize, func(event cloudevents.Event) {
+			defer c.inflight.Done()
+			ctx := context.Background()
+			c.receive(ctx, event, func(ctx context.Context, name string, req *Request) Responder {
+				return c.newSendResponder(ctx, name, req)
+			})
+		})
+	})
+	return c.pool
+}
@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/botless/commands/pkg/ratelimit"
+	"sync"
+	"time"
+)
+
+// RateLimits declares how often a command may run. Each limit applies per
+// command, zero limits are unlimited.
+type RateLimits struct {
+	// PerAuthor limits each author.
+	PerAuthor ratelimit.Limit
+	// PerChannel limits each channel.
+	PerChannel ratelimit.Limit
+	// Global limits the command across all authors and channels.
+	Global ratelimit.Limit
+}
+
+// override returns l with each limit set in o replacing its own.
+func (l RateLimits) override(o RateLimits) RateLimits {
+	if !o.PerAuthor.Unlimited() {
+		l.PerAuthor = o.PerAuthor
+	}
+	if !o.PerChannel.Unlimited() {
+		l.PerChannel = o.PerChannel
+	}
+	if !o.Global.Unlimited() {
+		l.Global = o.Global
+	}
+	return l
+}
+
+// limiters holds a Limiter per command and dimension.
+type limiters struct {
+	mu sync.Mutex
+	m  map[string]*ratelimit.Limiter
+}
+
+func (l *limiters) get(key string, limit ratelimit.Limit) *ratelimit.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.m == nil {
+		l.m = make(map[string]*ratelimit.Limiter)
+	}
+	lim, ok := l.m[key]
+	if !ok || lim.Limit() != limit {
+		lim = ratelimit.New(limit)
+		l.m[key] = lim
+	}
+	return lim
+}
+
+// rateLimit checks the limits of cmd for req. When denied, notice is the
+// message to reply with, set once per window and only if RateLimitReply is
+// set.
+func (c *Commands) rateLimit(cmd *Command, req *Request) (denied bool, notice string) {
+	limits := c.RateLimits
+	if cmd.RateLimits != nil {
+		limits = limits.override(*cmd.RateLimits)
+	}
+	checks := []struct {
+		dimension string
+		key       string
+		limit     ratelimit.Limit
+		reason    string
+	}{
+		{"author", req.Author, limits.PerAuthor, "you are running `%s` too often"},
+		{"channel", req.Channel, limits.PerChannel, "`%s` is being run too often in this channel"},
+		{"global", "", limits.Global, "`%s` is being run too often"},
+	}
+	for _, check := range checks {
+		if check.limit.Unlimited() {
+			continue
+		}
+		d := c.limiters.get(cmd.Name+"/"+check.dimension, check.limit).Allow(check.key)
+		if d.Allowed {
+			continue
+		}
+		if d.FirstDenied && c.RateLimitReply {
+			return true, fmt.Sprintf(check.reason+", slow down and try again in %s.", cmd.Name, d.RetryAfter.Round(time.Second))
+		}
+		return true, ""
+	}
+	return false, ""
+}
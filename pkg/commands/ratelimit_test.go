@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"github.com/botless/commands/pkg/ratelimit"
+	"github.com/botless/events/pkg/events"
+	"testing"
+	"time"
+)
+
+func TestRateLimitOverride(t *testing.T) {
+	c := &Commands{
+		RateLimits: RateLimits{
+			PerAuthor:  ratelimit.Limit{Events: 1, Per: time.Hour},
+			PerChannel: ratelimit.Limit{Events: 2, Per: time.Hour},
+		},
+		RateLimitReply: true,
+	}
+	cmd := &Command{
+		Name:       "vote",
+		RateLimits: &RateLimits{PerAuthor: ratelimit.Limit{Events: 10, Per: time.Hour}},
+	}
+	request := func(author, channel string) *Request {
+		return &Request{Command: events.Command{Author: author, Channel: channel}}
+	}
+
+	// The command's own limit replaces the author default.
+	for i := 0; i < 2; i++ {
+		if denied, _ := c.rateLimit(cmd, request("alice", "c1")); denied {
+			t.Fatalf("vote %d of alice denied", i+1)
+		}
+	}
+	// The channel default still applies.
+	denied, notice := c.rateLimit(cmd, request("bob", "c1"))
+	if !denied {
+		t.Fatalf("third vote in the channel allowed")
+	}
+	if want := "`vote` is being run too often in this channel, slow down and try again in 30m0s."; notice != want {
+		t.Errorf("notice = %q, want %q", notice, want)
+	}
+	if denied, _ := c.rateLimit(cmd, request("bob", "c2")); denied {
+		t.Errorf("vote in another channel denied")
+	}
+}
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"context"
+	"github.com/botless/commands/pkg/dedupe"
+	"github.com/botless/events/pkg/events"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"net/http"
+	"testing"
+)
+
+// blockingRegistry holds echo and wait, which replies once release is
+// closed. Each wait signals started when it begins.
+func blockingRegistry(t *testing.T, started chan<- struct{}, release <-chan struct{}) *Registry {
+	t.Helper()
+	r := testRegistry(t)
+	err := r.Register(Command{
+		Name: "wait",
+		Handler: func(ctx context.Context, req *Request, resp Responder) {
+			started <- struct{}{}
+			<-release
+			resp.Reply("done")
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register: %s", err)
+	}
+	return r
+}
+
+func receive(t *testing.T, c *Commands, text string) *cloudevents.EventResponse {
+	t.Helper()
+	name, args := cutWord(text)
+	resp := &cloudevents.EventResponse{}
+	c.Receive(context.Background(), commandEvent(t, events.Command{Channel: "general", Author: "alice", Cmd: name, Args: args}), resp)
+	return resp
+}
+
+func TestReceiveQueueFull(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	ce := &failingClient{}
+	c := &Commands{Ce: ce, Registry: blockingRegistry(t, started, release), Workers: 1, QueueSize: 1}
+
+	// The worker is busy with the first, the second waits in the queue.
+	if resp := receive(t, c, "wait"); resp.Status != 0 {
+		t.Fatalf("first event status = %d", resp.Status)
+	}
+	<-started
+	if resp := receive(t, c, "echo queued"); resp.Status != 0 {
+		t.Fatalf("second event status = %d", resp.Status)
+	}
+	resp := receive(t, c, "echo rejected")
+	if resp.Status != http.StatusServiceUnavailable || resp.Reason != "queue full" {
+		t.Errorf("third event response = %d %q, want 503 queue full", resp.Status, resp.Reason)
+	}
+
+	close(release)
+	if err := c.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+	if len(ce.sent) != 2 {
+		t.Errorf("sent %d replies, want 2", len(ce.sent))
+	}
+}
+
+func TestReceiveSynchronous(t *testing.T) {
+	ce := &failingClient{failures: 1}
+	c := &Commands{Ce: ce, Registry: testRegistry(t), Synchronous: true, Dedupe: dedupe.NewLRU(0)}
+	event := commandEvent(t, events.Command{Channel: "general", Author: "alice", Cmd: "echo", Args: "hi"})
+
+	resp := &cloudevents.EventResponse{}
+	c.Receive(context.Background(), event, resp)
+	if resp.Status != http.StatusBadGateway || resp.Reason != "unavailable" {
+		t.Errorf("response = %d %q, want 502 unavailable", resp.Status, resp.Reason)
+	}
+
+	// The failed event was forgotten, so its redelivery is handled.
+	resp = &cloudevents.EventResponse{}
+	c.Receive(context.Background(), event, resp)
+	if resp.Status != 0 {
+		t.Errorf("redelivery response = %d %q, want none", resp.Status, resp.Reason)
+	}
+	if len(ce.sent) != 1 {
+		t.Errorf("sent %d replies, want 1", len(ce.sent))
+	}
+}
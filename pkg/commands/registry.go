@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/botless/commands/pkg/commands/args"
+	"github.com/botless/events/pkg/events"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Handler is invoked for each request routed to a registered command. Results
+// are sent back with r.
+type Handler func(ctx context.Context, req *Request, r Responder)
+
+// Command describes a command and how to handle it.
+type Command struct {
+	// Name is what the user types, e.g. "echo".
+	Name string
+	// Type is the CloudEvent type routed to this command. Defaults to
+	// CommandType(Name).
+	Type string
+	// Description is a one line summary of the command.
+	Description string
+	// Usage shows how to invoke the command, e.g. "echo <text>".
+	Usage string
+	// Examples are sample invocations.
+	Examples []string
+	// Aliases are alternative names that route to this command.
+	Aliases []string
+	// Args optionally declares the arguments the command accepts. When set,
+	// the arguments are parsed before the Handler is called and usage errors
+	// are sent back to the channel.
+	Args *args.Schema
+	// AdminOnly restricts the command to the admins of the ACL.
+	AdminOnly bool
+	// RateLimits optionally overrides the default rate limits of Commands
+	// for this command. Only the limits it sets are overridden, the others
+	// keep their defaults.
+	RateLimits *RateLimits
+
+	Handler Handler
+}
+
+// CommandType returns the CloudEvent type for the named command.
+func CommandType(name string) string {
+	return events.Bot.Type("command") + "." + strings.ToLower(name)
+}
+
+// Registry holds the set of known commands, indexed by name, alias and
+// CloudEvent type.
+type Registry struct {
+	mu       sync.RWMutex
+	byName   map[string]*Command
+	byType   map[string]*Command
+	disabled map[*Command]bool
+}
+
+// NewRegistry returns a registry holding only the built-in help command.
+func NewRegistry() *Registry {
+	r := &Registry{
+		byName:   make(map[string]*Command),
+		byType:   make(map[string]*Command),
+		disabled: make(map[*Command]bool),
+	}
+	if err := r.Register(helpCommand(r)); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// DefaultRegistry is used by Commands when no registry is given. Command
+// packages register themselves here from init.
+var DefaultRegistry = NewRegistry()
+
+// Register adds cmd to the DefaultRegistry.
+func Register(cmd Command) error {
+	return DefaultRegistry.Register(cmd)
+}
+
+// MustRegister adds cmd to the DefaultRegistry and panics on error.
+func MustRegister(cmd Command) {
+	if err := Register(cmd); err != nil {
+		panic(err)
+	}
+}
+
+// Register adds cmd to the registry. Names, aliases and types must be unique.
+func (r *Registry) Register(cmd Command) error {
+	if cmd.Name == "" {
+		return fmt.Errorf("command name is required")
+	}
+	if cmd.Handler == nil {
+		return fmt.Errorf("command %q has no handler", cmd.Name)
+	}
+	cmd.Name = strings.ToLower(cmd.Name)
+	if cmd.Type == "" {
+		cmd.Type = CommandType(cmd.Name)
+	}
+	if cmd.Usage == "" && cmd.Args != nil {
+		cmd.Usage = strings.TrimSpace(cmd.Name + " " + cmd.Args.Usage())
+	}
+
+	names := append([]string{cmd.Name}, cmd.Aliases...)
+	types := []string{cmd.Type}
+	for _, a := range cmd.Aliases {
+		types = append(types, CommandType(a))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, n := range names {
+		if _, found := r.byName[strings.ToLower(n)]; found {
+			return fmt.Errorf("command %q already registered", n)
+		}
+	}
+	for _, t := range types {
+		if _, found := r.byType[t]; found {
+			return fmt.Errorf("command type %q already registered", t)
+		}
+	}
+
+	c := &cmd
+	for _, n := range names {
+		r.byName[strings.ToLower(n)] = c
+	}
+	for _, t := range types {
+		r.byType[t] = c
+	}
+	return nil
+}
+
+// Lookup finds a command by name or alias.
+func (r *Registry) Lookup(name string) (*Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.byName[strings.ToLower(name)]
+	return c, ok
+}
+
+// LookupType finds a command by CloudEvent type.
+func (r *Registry) LookupType(t string) (*Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.byType[t]
+	return c, ok
+}
+
+// SetEnabled enables or disables the named command. Disabled commands are
+// ignored by the dispatcher.
+func (r *Registry) SetEnabled(name string, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.byName[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown command %q", name)
+	}
+	if enabled {
+		delete(r.disabled, c)
+	} else {
+		r.disabled[c] = true
+	}
+	return nil
+}
+
+// Enabled reports whether cmd may be dispatched to.
+func (r *Registry) Enabled(cmd *Command) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !r.disabled[cmd]
+}
+
+// Commands returns the registered commands sorted by name.
+func (r *Registry) Commands() []*Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[*Command]bool)
+	cmds := make([]*Command, 0, len(r.byName))
+	for _, c := range r.byName {
+		if !seen[c] {
+			seen[c] = true
+			cmds = append(cmds, c)
+		}
+	}
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name < cmds[j].Name
+	})
+	return cmds
+}
@@ -0,0 +1,307 @@
+// Package remind implements the remind command, which schedules messages to
+// be sent to a channel later. Reminders are kept in the Store of Commands so
+// they survive restarts, and delivered by a Scheduler.
+package remind
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/botless/commands/pkg/commands"
+	"github.com/botless/commands/pkg/store"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Namespace is the store namespace holding reminders.
+	Namespace = "remind"
+
+	reminderPrefix = "reminder/"
+	sequenceKey    = "sequence"
+
+	// maxPerChannel bounds the pending reminders of a channel.
+	maxPerChannel = 100
+	// maxDelay bounds how far ahead a reminder may be set.
+	maxDelay = 366 * 24 * time.Hour
+)
+
+// Reminder is a message to send to a channel at a given time.
+type Reminder struct {
+	ID string `json:"id"`
+	// Channel is where the reminder is sent.
+	Channel string `json:"channel"`
+	// Origin is the channel the reminder was set in.
+	Origin string `json:"origin"`
+	Author string `json:"author"`
+	// Personal reminders are addressed to Author.
+	Personal bool      `json:"personal,omitempty"`
+	Text     string    `json:"text"`
+	Due      time.Time `json:"due"`
+	Created  time.Time `json:"created"`
+	// Attempts counts the failed deliveries.
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// Message returns the text sent when r is due.
+func (r Reminder) Message() string {
+	if r.Personal {
+		return fmt.Sprintf("Reminder for %s: %s", commands.Mention(r.Author), r.Text)
+	}
+	return fmt.Sprintf("Reminder from %s: %s", commands.Mention(r.Author), r.Text)
+}
+
+// now is the current time, set by the tests to deliver reminders without
+// waiting for them.
+var now = time.Now
+
+// Command returns the remind command. Times are read in loc, which defaults
+// to UTC.
+func Command(loc *time.Location) commands.Command {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return commands.Command{
+		Name:        "remind",
+		Description: "Sends a reminder to you or a channel later.",
+		Usage:       "remind me|here|#channel <when> [to] <text> | remind list | remind cancel <id>",
+		Examples: []string{
+			"remind me in 20m to stretch",
+			"remind #general at 9am tomorrow standup!",
+			"remind here friday at 16:00 to ship it",
+			"remind list",
+			"remind cancel 12",
+		},
+		Handler: func(ctx context.Context, req *commands.Request, resp commands.Responder) {
+			if req.Store == nil {
+				resp.ReplyError(fmt.Errorf("reminders are not available, no store is configured"))
+				return
+			}
+			words := strings.Fields(req.Args)
+			if len(words) == 0 {
+				words = []string{"list"}
+			}
+			switch strings.ToLower(words[0]) {
+			case "list", "ls":
+				list(ctx, req, resp, loc)
+			case "cancel", "rm":
+				if len(words) != 2 {
+					resp.ReplyError(fmt.Errorf("usage: `remind cancel <id>`"))
+					return
+				}
+				cancel(ctx, req, resp, words[1])
+			default:
+				add(ctx, req, resp, words, loc)
+			}
+		},
+	}
+}
+
+func add(ctx context.Context, req *commands.Request, resp commands.Responder, words []string, loc *time.Location) {
+	r := Reminder{
+		Origin:  req.Channel,
+		Author:  req.Author,
+		Created: now(),
+	}
+	who := words[0]
+	switch channel := parseChannel(who); {
+	case strings.EqualFold(who, "me"):
+		r.Channel, r.Personal = req.Channel, true
+	case strings.EqualFold(who, "here"):
+		r.Channel = req.Channel
+	case channel != "":
+		r.Channel = channel
+	default:
+		resp.ReplyError(fmt.Errorf("expected `me`, `here` or a #channel, got %q", who))
+		return
+	}
+
+	due, n, err := ParseWhen(words[1:], r.Created.In(loc))
+	if err != nil {
+		resp.ReplyError(err)
+		return
+	}
+	rest := words[1+n:]
+	if len(rest) > 0 && strings.EqualFold(rest[0], "to") {
+		rest = rest[1:]
+	}
+	if len(rest) == 0 {
+		resp.ReplyError(fmt.Errorf("what should I remind about? e.g. `remind me in 20m to stretch`"))
+		return
+	}
+	if due.Sub(r.Created) > maxDelay {
+		resp.ReplyError(fmt.Errorf("reminders can be set at most a year ahead"))
+		return
+	}
+	r.Text = strings.Join(rest, " ")
+	r.Due = due
+
+	pending, err := channelReminders(ctx, req.Store, req.Channel)
+	if err != nil {
+		resp.ReplyError(fmt.Errorf("failed to list reminders: %s", err))
+		return
+	}
+	if len(pending) >= maxPerChannel {
+		resp.ReplyError(fmt.Errorf("this channel already has %d reminders, cancel some first", len(pending)))
+		return
+	}
+
+	seq, err := req.Store.Incr(ctx, Namespace, sequenceKey, 1)
+	if err != nil {
+		resp.ReplyError(fmt.Errorf("failed to save reminder: %s", err))
+		return
+	}
+	r.ID = strconv.FormatInt(seq, 10)
+	if err := save(ctx, req.Store, r); err != nil {
+		resp.ReplyError(fmt.Errorf("failed to save reminder: %s", err))
+		return
+	}
+
+	whom := "you"
+	if !r.Personal {
+		whom = commands.MentionChannel(r.Channel)
+	}
+	resp.Reply(fmt.Sprintf("I will remind %s %s, id %s", whom, describe(r.Due, r.Created, loc), r.ID))
+}
+
+func list(ctx context.Context, req *commands.Request, resp commands.Responder, loc *time.Location) {
+	reminders, err := channelReminders(ctx, req.Store, req.Channel)
+	if err != nil {
+		resp.ReplyError(fmt.Errorf("failed to list reminders: %s", err))
+		return
+	}
+	if len(reminders) == 0 {
+		resp.Reply("no reminders set in this channel")
+		return
+	}
+	sb := strings.Builder{}
+	sb.WriteString("Reminders:")
+	for _, r := range reminders {
+		target := commands.MentionChannel(r.Channel)
+		if r.Personal {
+			target = commands.Mention(r.Author)
+		}
+		sb.WriteString(fmt.Sprintf("\n• `%s` %s for %s: %s", r.ID, describe(r.Due, now(), loc), target, r.Text))
+	}
+	resp.Reply(sb.String())
+}
+
+func cancel(ctx context.Context, req *commands.Request, resp commands.Responder, id string) {
+	r, found, err := load(ctx, req.Store, id)
+	if err != nil {
+		resp.ReplyError(fmt.Errorf("failed to load reminder: %s", err))
+		return
+	}
+	// Reminders can only be cancelled where they were set or are sent to.
+	if !found || (r.Origin != req.Channel && r.Channel != req.Channel) {
+		resp.ReplyError(fmt.Errorf("no reminder %q in this channel", id))
+		return
+	}
+	if err := req.Store.Delete(ctx, Namespace, reminderPrefix+id); err != nil {
+		resp.ReplyError(fmt.Errorf("failed to cancel reminder: %s", err))
+		return
+	}
+	resp.Reply(fmt.Sprintf("cancelled reminder %s: %s", id, r.Text))
+}
+
+// parseChannel returns the channel named by word, "#general" or Slack's
+// "<#C0123|general>" and "<#C0123>", which name it by ID. It returns "" when
+// word does not name a channel.
+func parseChannel(word string) string {
+	if strings.HasPrefix(word, "<#") && strings.HasSuffix(word, ">") {
+		id := strings.TrimSuffix(strings.TrimPrefix(word, "<#"), ">")
+		if i := strings.Index(id, "|"); i >= 0 {
+			id = id[:i]
+		}
+		return id
+	}
+	if strings.HasPrefix(word, "#") {
+		return strings.TrimPrefix(word, "#")
+	}
+	return ""
+}
+
+// describe formats due for replies, e.g. "at Mon Jan 2 15:04 UTC (in 20m)".
+func describe(due, from time.Time, loc *time.Location) string {
+	return fmt.Sprintf("at %s (in %s)", due.In(loc).Format("Mon Jan 2 15:04 MST"), humanize(due.Sub(from)))
+}
+
+// humanize formats d in days, hours and minutes, or seconds when under a
+// minute, e.g. "1d 7h" or "1h30m".
+func humanize(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	d = d.Round(time.Minute)
+	days, hours, minutes := d/(24*time.Hour), d%(24*time.Hour)/time.Hour, d%time.Hour/time.Minute
+	var parts []string
+	if days > 0 {
+		parts = append(parts, fmt.Sprintf("%dd", days))
+	}
+	if hours > 0 {
+		parts = append(parts, fmt.Sprintf("%dh", hours))
+	}
+	if minutes > 0 {
+		parts = append(parts, fmt.Sprintf("%dm", minutes))
+	}
+	return strings.Join(parts, " ")
+}
+
+// channelReminders returns the reminders set in or sent to channel, soonest
+// first.
+func channelReminders(ctx context.Context, s store.Store, channel string) ([]Reminder, error) {
+	all, err := pending(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	var reminders []Reminder
+	for _, r := range all {
+		if r.Origin == channel || r.Channel == channel {
+			reminders = append(reminders, r)
+		}
+	}
+	return reminders, nil
+}
+
+// pending returns all stored reminders, soonest first. Entries that can not
+// be decoded are skipped.
+func pending(ctx context.Context, s store.Store) ([]Reminder, error) {
+	entries, err := s.Scan(ctx, Namespace, reminderPrefix)
+	if err != nil {
+		return nil, err
+	}
+	reminders := make([]Reminder, 0, len(entries))
+	for _, e := range entries {
+		r := Reminder{}
+		if err := json.Unmarshal(e.Value, &r); err != nil {
+			continue
+		}
+		reminders = append(reminders, r)
+	}
+	sort.Slice(reminders, func(i, j int) bool {
+		return reminders[i].Due.Before(reminders[j].Due)
+	})
+	return reminders, nil
+}
+
+func load(ctx context.Context, s store.Store, id string) (Reminder, bool, error) {
+	r := Reminder{}
+	data, found, err := s.Get(ctx, Namespace, reminderPrefix+id)
+	if err != nil || !found {
+		return r, false, err
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, false, err
+	}
+	return r, true, nil
+}
+
+func save(ctx context.Context, s store.Store, r Reminder) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return s.Set(ctx, Namespace, reminderPrefix+r.ID, data, 0)
+}
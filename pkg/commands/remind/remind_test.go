@@ -0,0 +1,66 @@
+package remind
+
+import (
+	"context"
+	"github.com/botless/commands/pkg/commands/commandstest"
+	"github.com/botless/commands/pkg/store"
+	"testing"
+	"time"
+)
+
+func TestParseChannel(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"#general", "general"},
+		{"<#C0123|general>", "C0123"},
+		{"<#C0123>", "C0123"},
+		{"general", ""},
+		{"#", ""},
+		{"<@U0123>", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			if got := parseChannel(tt.word); got != tt.want {
+				t.Errorf("parseChannel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemind(t *testing.T) {
+	start := time.Date(2019, time.January, 2, 10, 0, 0, 0, time.UTC)
+	now = commandstest.NewClock(start).Now
+	defer func() { now = time.Now }()
+	s := store.NewMemory()
+	cmd := Command(time.UTC)
+
+	steps := []struct {
+		args string
+		want string
+	}{
+		{"list", "no reminders set in this channel"},
+		{"me in 20m to stretch", "I will remind you at Wed Jan 2 10:20 UTC (in 20m), id 1"},
+		{"<#C0123|random> tomorrow standup!", "I will remind <#C0123> at Thu Jan 3 09:00 UTC (in 23h), id 2"},
+		{"#random at noon to eat", "I will remind #random at Wed Jan 2 12:00 UTC (in 2h), id 3"},
+		{"someone in 20m hi", "error: expected `me`, `here` or a #channel, got \"someone\""},
+		{"here in 20m", "error: what should I remind about? e.g. `remind me in 20m to stretch`"},
+		{"list", "Reminders:" +
+			"\n• `1` at Wed Jan 2 10:20 UTC (in 20m) for <@U0BOB>: stretch" +
+			"\n• `3` at Wed Jan 2 12:00 UTC (in 2h) for #random: eat" +
+			"\n• `2` at Thu Jan 3 09:00 UTC (in 23h) for <#C0123>: standup!"},
+		{"cancel 3", "cancelled reminder 3: eat"},
+		{"cancel 3", "error: no reminder \"3\" in this channel"},
+	}
+	for _, step := range steps {
+		if got := commandstest.Run(s, cmd, "U0BOB", step.args); got != step.want {
+			t.Errorf("remind %s = %q, want %q", step.args, got, step.want)
+		}
+	}
+
+	r, found, err := load(context.Background(), s, "2")
+	if err != nil || !found || r.Channel != "C0123" || r.Origin != "general" {
+		t.Errorf("load() = %+v, %t, %v, want reminder 2 for C0123", r, found, err)
+	}
+}
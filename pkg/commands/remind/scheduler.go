@@ -0,0 +1,91 @@
+package remind
+
+import (
+	"context"
+	"github.com/botless/commands/pkg/commands"
+	"github.com/botless/commands/pkg/logging"
+	"github.com/botless/commands/pkg/store"
+	"github.com/botless/events/pkg/events"
+	"time"
+)
+
+// Scheduler delivers due reminders. Every replica may run one: a reminder is
+// claimed in the Store and removed from it before it is sent, so as long as
+// the replicas share the Store it is sent at most once. A replica dying while
+// sending loses the reminder rather than risk sending it twice.
+type Scheduler struct {
+	Store  store.Store
+	Sender *commands.Sender
+
+	// Interval is how often due reminders are looked for. Defaults to 10s.
+	Interval time.Duration
+	// MaxAttempts is the number of times delivering a reminder is tried
+	// before it is dropped. Defaults to 5.
+	MaxAttempts int
+
+	// Logger defaults to logging.Default().
+	Logger *logging.Logger
+}
+
+// Run delivers due reminders until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.deliverer().Run(ctx)
+}
+
+func (s *Scheduler) deliverDue(ctx context.Context) {
+	s.deliverer().DeliverDue(ctx)
+}
+
+func (s *Scheduler) deliverer() *commands.Deliverer {
+	return &commands.Deliverer{
+		Store:       s.Store,
+		Sender:      s.Sender,
+		Namespace:   Namespace,
+		Name:        "reminder",
+		Due:         s.due,
+		Take:        s.take,
+		Interval:    s.Interval,
+		MaxAttempts: s.MaxAttempts,
+		Logger:      s.Logger,
+	}
+}
+
+func (s *Scheduler) due(ctx context.Context) ([]string, error) {
+	reminders, err := pending(ctx, s.Store)
+	if err != nil {
+		return nil, err
+	}
+	t := now()
+	var ids []string
+	for _, r := range reminders {
+		if r.Due.After(t) {
+			break
+		}
+		ids = append(ids, r.ID)
+	}
+	return ids, nil
+}
+
+// take removes the reminder id, it is put back when sending fails.
+func (s *Scheduler) take(ctx context.Context, id string) (*commands.Delivery, error) {
+	// Load again, the reminder may have been cancelled or delivered since it
+	// was listed.
+	r, found, err := load(ctx, s.Store, id)
+	if err != nil || !found {
+		return nil, err
+	}
+	if err := s.Store.Delete(ctx, Namespace, reminderPrefix+id); err != nil {
+		return nil, err
+	}
+	return &commands.Delivery{
+		Message:  events.Message{Channel: r.Channel, Text: r.Message()},
+		Attempts: r.Attempts,
+		Failed: func(ctx context.Context, attempts int, retry bool) error {
+			if !retry {
+				return nil
+			}
+			r.Attempts = attempts
+			return save(ctx, s.Store, r)
+		},
+	}, nil
+}
@@ -0,0 +1,210 @@
+package remind
+
+import (
+	"context"
+	"github.com/botless/commands/pkg/commands"
+	"github.com/botless/commands/pkg/commands/commandstest"
+	"github.com/botless/commands/pkg/logging"
+	"github.com/botless/commands/pkg/store"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newScheduler(s store.Store, ce *commandstest.Client) *Scheduler {
+	return &Scheduler{
+		Store:       s,
+		Sender:      &commands.Sender{Ce: ce},
+		MaxAttempts: 2,
+		Logger:      logging.New(ioutil.Discard, logging.Error, false),
+	}
+}
+
+func mustSave(t *testing.T, s store.Store, r Reminder) {
+	t.Helper()
+	if err := save(context.Background(), s, r); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+}
+
+func pendingIDs(t *testing.T, s store.Store) []string {
+	t.Helper()
+	reminders, err := pending(context.Background(), s)
+	if err != nil {
+		t.Fatalf("pending() error = %v", err)
+	}
+	var ids []string
+	for _, r := range reminders {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func TestSchedulerDeliversDue(t *testing.T) {
+	start := time.Date(2019, time.January, 2, 10, 0, 0, 0, time.UTC)
+	s := store.NewMemory()
+	mustSave(t, s, Reminder{ID: "1", Channel: "general", Author: "U012AB", Personal: true, Text: "stretch", Due: start.Add(time.Minute)})
+	mustSave(t, s, Reminder{ID: "2", Channel: "general", Author: "bob", Text: "standup", Due: start.Add(time.Hour)})
+	ce := &commandstest.Client{}
+	sched := newScheduler(s, ce)
+
+	clock := commandstest.NewClock(start)
+	now = clock.Now
+	defer func() { now = time.Now }()
+	sched.deliverDue(context.Background())
+	if got := ce.Messages(); len(got) != 0 {
+		t.Fatalf("sent %q before any reminder was due", got)
+	}
+
+	clock.Set(start.Add(time.Minute))
+	sched.deliverDue(context.Background())
+	if got, want := ce.Messages(), []string{"Reminder for <@U012AB>: stretch"}; !equal(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+	if got, want := pendingIDs(t, s), []string{"2"}; !equal(got, want) {
+		t.Errorf("pending %q, want %q", got, want)
+	}
+
+	clock.Set(start.Add(2 * time.Hour))
+	sched.deliverDue(context.Background())
+	sched.deliverDue(context.Background())
+	if got, want := ce.Messages(), []string{"Reminder for <@U012AB>: stretch", "Reminder from bob: standup"}; !equal(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+	if got := pendingIDs(t, s); len(got) != 0 {
+		t.Errorf("pending %q after delivering all", got)
+	}
+}
+
+func TestSchedulerRetries(t *testing.T) {
+	start := time.Date(2019, time.January, 2, 10, 0, 0, 0, time.UTC)
+	clock := commandstest.NewClock(start)
+	now = clock.Now
+	defer func() { now = time.Now }()
+	s := store.NewMemory()
+	mustSave(t, s, Reminder{ID: "1", Channel: "general", Author: "bob", Text: "dropped", Due: start.Add(-time.Second)})
+	mustSave(t, s, Reminder{ID: "2", Channel: "general", Author: "bob", Text: "sent", Due: start})
+	ce := &commandstest.Client{Failures: 3}
+	sched := newScheduler(s, ce)
+
+	// Both fail once and are put back.
+	sched.deliverDue(context.Background())
+	if got, want := pendingIDs(t, s), []string{"1", "2"}; !equal(got, want) {
+		t.Fatalf("pending %q, want %q", got, want)
+	}
+	r, _, err := load(context.Background(), s, "1")
+	if err != nil || r.Attempts != 1 {
+		t.Fatalf("load() = %+v, %v, want 1 attempt", r, err)
+	}
+
+	// 1 fails for the last time and is dropped, 2 is sent.
+	sched.deliverDue(context.Background())
+	if got, want := ce.Messages(), []string{"Reminder from bob: sent"}; !equal(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+	if got := pendingIDs(t, s); len(got) != 0 {
+		t.Errorf("pending %q, want none", got)
+	}
+	// The claims were released.
+	for _, id := range []string{"1", "2"} {
+		if claimed, err := commands.Claim(context.Background(), s, Namespace, id); !claimed || err != nil {
+			t.Errorf("Claim(%s) = %t, %v, want released", id, claimed, err)
+		}
+	}
+}
+
+// contextStore fails once the context is done, as a Remote store does.
+type contextStore struct {
+	store.Store
+}
+
+func (s contextStore) Set(ctx context.Context, ns, key string, value []byte, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.Set(ctx, ns, key, value, ttl)
+}
+
+func (s contextStore) Delete(ctx context.Context, ns, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.Delete(ctx, ns, key)
+}
+
+func TestSchedulerStopped(t *testing.T) {
+	start := time.Date(2019, time.January, 2, 10, 0, 0, 0, time.UTC)
+	clock := commandstest.NewClock(start)
+	now = clock.Now
+	defer func() { now = time.Now }()
+	s := contextStore{store.NewMemory()}
+	mustSave(t, s, Reminder{ID: "1", Channel: "general", Author: "bob", Text: "kept", Due: start})
+
+	// Stopping while sending puts the reminder back, without counting it
+	// as a failed delivery.
+	ctx, cancel := context.WithCancel(context.Background())
+	ce := &commandstest.Client{Cancel: cancel}
+	newScheduler(s, ce).deliverDue(ctx)
+	r, found, err := load(context.Background(), s, "1")
+	if err != nil || !found || r.Attempts != 0 {
+		t.Fatalf("load() = %+v, %t, %v, want the reminder back", r, found, err)
+	}
+
+	ce.SetCancel(nil)
+	newScheduler(s, ce).deliverDue(context.Background())
+	if got, want := ce.Messages(), []string{"Reminder from bob: kept"}; !equal(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestSchedulerSharedStore(t *testing.T) {
+	start := time.Date(2019, time.January, 2, 10, 0, 0, 0, time.UTC)
+	clock := commandstest.NewClock(start)
+	now = clock.Now
+	defer func() { now = time.Now }()
+	s := store.NewMemory()
+	const count = 50
+	var want []string
+	for i := 0; i < count; i++ {
+		id := strconv.Itoa(i)
+		mustSave(t, s, Reminder{ID: id, Channel: "general", Author: "bob", Text: id, Due: start})
+		want = append(want, "Reminder from bob: "+id)
+	}
+
+	// Replicas sharing the store each deliver a reminder at most once.
+	ce := &commandstest.Client{}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		sched := newScheduler(s, ce)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				sched.deliverDue(context.Background())
+			}
+		}()
+	}
+	wg.Wait()
+
+	got := ce.Messages()
+	sort.Strings(got)
+	sort.Strings(want)
+	if !equal(got, want) {
+		t.Errorf("sent %d reminders %q, want each of %d once", len(got), got, count)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
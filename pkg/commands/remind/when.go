@@ -0,0 +1,223 @@
+package remind
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultClock is the time of day used when only a day is given, e.g.
+// "tomorrow".
+const defaultClock = 9 * time.Hour
+
+var (
+	clockPattern    = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+	durationPattern = regexp.MustCompile(`^(\d+)([a-z]+)$`)
+)
+
+// units maps the units accepted after "in" to their duration.
+var units = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday,
+	"wednesday": time.Wednesday, "thursday": time.Thursday,
+	"friday": time.Friday, "saturday": time.Saturday,
+}
+
+// ParseWhen parses the time at the start of words, relative to now and in the
+// location of now. It returns the time and the number of words used.
+//
+// Accepted forms are "in <duration>", e.g. "in 20m" or "in 1 hour and 30
+// minutes", "at <clock> [on] [today|tomorrow|<weekday>]", e.g. "at 9am
+// tomorrow", and "[today|tomorrow|<weekday>] [at <clock>]", e.g. "tomorrow" or
+// "friday at 17:30". A clock without a day is its next occurrence, a day
+// without a clock is at 9am.
+func ParseWhen(words []string, now time.Time) (time.Time, int, error) {
+	if len(words) == 0 {
+		return time.Time{}, 0, fmt.Errorf("missing time")
+	}
+	switch strings.ToLower(words[0]) {
+	case "in":
+		d, n, err := parseDuration(words[1:])
+		if err != nil {
+			return time.Time{}, 0, err
+		}
+		return now.Add(d), 1 + n, nil
+	case "at":
+		clock, n, err := parseClock(words[1:])
+		if err != nil {
+			return time.Time{}, 0, err
+		}
+		n++
+		day, dn := parseDay(words[n:])
+		due, err := resolve(now, day, clock)
+		return due, n + dn, err
+	}
+	day, n := parseDay(words)
+	if n == 0 {
+		return time.Time{}, 0, fmt.Errorf("expected `in`, `at`, `today`, `tomorrow` or a weekday, got %q", words[0])
+	}
+	clock := defaultClock
+	hasClock := false
+	if n < len(words) && strings.ToLower(words[n]) == "at" {
+		c, cn, err := parseClock(words[n+1:])
+		if err != nil {
+			return time.Time{}, 0, err
+		}
+		clock, hasClock = c, true
+		n += 1 + cn
+	}
+	if day == "today" && !hasClock {
+		return time.Time{}, 0, fmt.Errorf("`today` needs a time, e.g. `today at 5pm`")
+	}
+	due, err := resolve(now, day, clock)
+	return due, n, err
+}
+
+// parseDuration parses durations like "20m", "1h30m", "2 hours" or "an hour
+// and 10 minutes", stopping at the first word that is not part of one.
+func parseDuration(words []string) (time.Duration, int, error) {
+	var total time.Duration
+	n := 0
+	for n < len(words) {
+		w := strings.ToLower(words[n])
+		if w == "and" && total > 0 {
+			n++
+			continue
+		}
+		if d, err := time.ParseDuration(w); err == nil && d > 0 {
+			total += d
+			n++
+			continue
+		}
+		if m := durationPattern.FindStringSubmatch(w); m != nil {
+			if unit, ok := units[m[2]]; ok {
+				count, _ := strconv.Atoi(m[1])
+				total += time.Duration(count) * unit
+				n++
+				continue
+			}
+		}
+		if n+1 < len(words) {
+			if unit, ok := units[strings.ToLower(words[n+1])]; ok {
+				count, err := strconv.Atoi(w)
+				if w == "a" || w == "an" {
+					count, err = 1, nil
+				}
+				if err == nil && count > 0 {
+					total += time.Duration(count) * unit
+					n += 2
+					continue
+				}
+			}
+		}
+		break
+	}
+	if total <= 0 {
+		return 0, 0, fmt.Errorf("expected a duration after `in`, e.g. `in 20m` or `in 2 hours`")
+	}
+	// A trailing "and" belongs to the text.
+	if strings.ToLower(words[n-1]) == "and" {
+		n--
+	}
+	return total, n, nil
+}
+
+// parseClock parses a time of day like "9am", "9:30 pm", "17:00" or "noon".
+func parseClock(words []string) (time.Duration, int, error) {
+	if len(words) == 0 {
+		return 0, 0, fmt.Errorf("expected a time after `at`, e.g. `at 9am` or `at 17:30`")
+	}
+	w := strings.ToLower(words[0])
+	switch w {
+	case "noon":
+		return 12 * time.Hour, 1, nil
+	case "midnight":
+		return 0, 1, nil
+	}
+	m := clockPattern.FindStringSubmatch(w)
+	if m == nil {
+		return 0, 0, fmt.Errorf("invalid time %q, expected e.g. `9am` or `17:30`", words[0])
+	}
+	n := 1
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	meridiem := m[3]
+	if meridiem == "" && len(words) > 1 {
+		if next := strings.ToLower(words[1]); next == "am" || next == "pm" {
+			meridiem = next
+			n++
+		}
+	}
+	if meridiem != "" {
+		if hour < 1 || hour > 12 {
+			return 0, 0, fmt.Errorf("invalid time %q", words[0])
+		}
+		hour %= 12
+		if meridiem == "pm" {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return 0, 0, fmt.Errorf("invalid time %q", words[0])
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, n, nil
+}
+
+// parseDay parses an optional day: "today", "tomorrow" or a weekday, optionally
+// preceded by "on".
+func parseDay(words []string) (string, int) {
+	n := 0
+	if len(words) > 0 && strings.ToLower(words[0]) == "on" {
+		n++
+	}
+	if n < len(words) {
+		w := strings.ToLower(words[n])
+		if _, ok := weekdays[w]; ok || w == "today" || w == "tomorrow" {
+			return w, n + 1
+		}
+	}
+	return "", 0
+}
+
+// resolve returns the first time at clock on day after now. Without a day the
+// clock is today if still ahead, otherwise tomorrow.
+func resolve(now time.Time, day string, clock time.Duration) (time.Time, error) {
+	y, m, d := now.Date()
+	at := func(offset int) time.Time {
+		midnight := time.Date(y, m, d+offset, 0, 0, 0, 0, now.Location())
+		return time.Date(midnight.Year(), midnight.Month(), midnight.Day(),
+			int(clock/time.Hour), int(clock%time.Hour/time.Minute), 0, 0, now.Location())
+	}
+	switch day {
+	case "":
+		if due := at(0); due.After(now) {
+			return due, nil
+		}
+		return at(1), nil
+	case "today":
+		due := at(0)
+		if !due.After(now) {
+			return time.Time{}, fmt.Errorf("%s has already passed today", due.Format("15:04"))
+		}
+		return due, nil
+	case "tomorrow":
+		return at(1), nil
+	}
+	offset := (int(weekdays[day]) - int(now.Weekday()) + 7) % 7
+	if due := at(offset); due.After(now) {
+		return due, nil
+	}
+	return at(offset + 7), nil
+}
@@ -0,0 +1,86 @@
+package remind
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWhen(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2019, time.January, 2, 10, 0, 0, 0, time.UTC)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2019, time.January, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		when string
+		want time.Time
+		n    int
+	}{
+		{"in 20m", now.Add(20 * time.Minute), 2},
+		{"in 1h30m to stretch", now.Add(90 * time.Minute), 2},
+		{"in 1 hour and 30 minutes to stretch", now.Add(90 * time.Minute), 6},
+		{"in an hour", now.Add(time.Hour), 3},
+		{"in 2 days", now.Add(48 * time.Hour), 3},
+		{"in 2h and stretch", now.Add(2 * time.Hour), 2},
+		{"at 11am", at(2, 11, 0), 2},
+		{"at 9am", at(3, 9, 0), 2},
+		{"at 5 pm friday", at(4, 17, 0), 4},
+		{"at 17:30 on monday ship it", at(7, 17, 30), 4},
+		{"at noon tomorrow", at(3, 12, 0), 3},
+		{"at midnight", at(3, 0, 0), 2},
+		{"tomorrow", at(3, 9, 0), 1},
+		{"Tomorrow at 8:15am standup", at(3, 8, 15), 3},
+		{"friday at noon", at(4, 12, 0), 3},
+		{"wednesday", at(9, 9, 0), 1},
+		{"wednesday at 11am", at(2, 11, 0), 3},
+		{"today at 11pm", at(2, 23, 0), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.when, func(t *testing.T) {
+			got, n, err := ParseWhen(strings.Fields(tt.when), now)
+			if err != nil {
+				t.Fatalf("ParseWhen() error = %v", err)
+			}
+			if !got.Equal(tt.want) || n != tt.n {
+				t.Errorf("ParseWhen() = %s, %d, want %s, %d", got, n, tt.want, tt.n)
+			}
+		})
+	}
+}
+
+func TestParseWhenErrors(t *testing.T) {
+	now := time.Date(2019, time.January, 2, 10, 0, 0, 0, time.UTC)
+	for _, when := range []string{
+		"",
+		"later",
+		"in",
+		"in soon",
+		"at",
+		"at 13pm",
+		"at 24:00",
+		"at 9:60",
+		"today",
+		"today at 9am",
+		"friday at lunch",
+	} {
+		t.Run(when, func(t *testing.T) {
+			if got, _, err := ParseWhen(strings.Fields(when), now); err == nil {
+				t.Errorf("ParseWhen() = %s, want an error", got)
+			}
+		})
+	}
+}
+
+func TestParseWhenLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+10", 10*60*60)
+	// Still the 1st in UTC.
+	now := time.Date(2019, time.January, 2, 8, 0, 0, 0, loc)
+	got, _, err := ParseWhen([]string{"tomorrow"}, now)
+	if err != nil {
+		t.Fatalf("ParseWhen() error = %v", err)
+	}
+	if want := time.Date(2019, time.January, 3, 9, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("ParseWhen() = %s, want %s", got, want)
+	}
+}
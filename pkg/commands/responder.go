@@ -0,0 +1,228 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/botless/commands/pkg/commands/args"
+	"github.com/botless/commands/pkg/logging"
+	"github.com/botless/commands/pkg/store"
+	"github.com/botless/commands/pkg/tracing"
+	"github.com/botless/events/pkg/events"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents/types"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+const (
+	command_source_template = "//botless/command/%s" // command name
+)
+
+// Responder sends the result of a command back to where it came from.
+type Responder interface {
+	// Reply sends text to the channel the command came from.
+	Reply(text string)
+	// ReplyMessage sends msg. An empty msg.Channel defaults to the channel the
+	// command came from.
+	ReplyMessage(msg events.Message)
+	// ReplyError reports err to the channel the command came from.
+	ReplyError(err error)
+}
+
+// Request is a decoded command invocation.
+type Request struct {
+	events.Command
+
+	// Event is the CloudEvent that carried the command.
+	Event cloudevents.Event
+
+	// Params holds the parsed arguments when the command declares Args.
+	Params *args.Values
+
+	// Store holds the state of stateful commands, namespaced by command
+	// name. Nil when Commands has no Store.
+	Store store.Store
+}
+
+// Text adapts a pure text transformation into a Handler. The returned string
+// is sent as the reply, a returned error is sent with ReplyError.
+func Text(fn func(args string) (string, error)) Handler {
+	return func(ctx context.Context, req *Request, r Responder) {
+		text, err := fn(req.Args)
+		if err != nil {
+			r.ReplyError(err)
+			return
+		}
+		r.Reply(text)
+	}
+}
+
+// slackUserID matches the IDs Slack gives users and bots.
+var slackUserID = regexp.MustCompile(`^[UW][A-Z0-9]+$`)
+
+// IsUserID reports whether user is a Slack user ID, e.g. "U0123".
+func IsUserID(user string) bool {
+	return slackUserID.MatchString(user)
+}
+
+// Mention formats the Slack user ID user, such as the Author of a command, so
+// the user is notified, e.g. "<@U0123>". Other names are returned as is.
+func Mention(user string) string {
+	if IsUserID(user) {
+		return "<@" + user + ">"
+	}
+	return user
+}
+
+// slackChannelID matches the IDs Slack gives channels and conversations.
+var slackChannelID = regexp.MustCompile(`^[CGD][A-Z0-9]+$`)
+
+// MentionChannel formats the Slack channel ID channel so it links to the
+// channel, e.g. "<#C0123>". Other names are prefixed with "#".
+func MentionChannel(channel string) string {
+	if slackChannelID.MatchString(channel) {
+		return "<#" + channel + ">"
+	}
+	return "#" + channel
+}
+
+// CommandSource returns the CloudEvent source used for responses of the named
+// command.
+func CommandSource(name string) types.URLRef {
+	return *types.ParseURLRef(fmt.Sprintf(command_source_template, name))
+}
+
+// ResponseEvent builds a botless.bot.response event for msg, propagating the
+// extensions of parent so the response can be correlated with the command.
+func ResponseEvent(name string, parent cloudevents.Event, msg events.Message) cloudevents.Event {
+	var extensions map[string]interface{}
+	if parent.Context != nil {
+		if ext := parent.Context.AsV02().Extensions; len(ext) > 0 {
+			extensions = make(map[string]interface{}, len(ext))
+			for k, v := range ext {
+				extensions[k] = v
+			}
+		}
+	}
+	return cloudevents.Event{
+		Context: cloudevents.EventContextV02{
+			Type:       events.Bot.Type("response"),
+			Source:     CommandSource(name),
+			Extensions: extensions,
+		}.AsV02(),
+		Data: msg,
+	}
+}
+
+// traceparent returns the trace context carried by event, if any.
+func traceparent(event cloudevents.Event) string {
+	tp, _ := event.Context.AsV02().Extensions[tracing.TraceparentExtension].(string)
+	return tp
+}
+
+// setTraceparent makes event carry the trace context of span.
+func setTraceparent(event *cloudevents.Event, span *tracing.Span) {
+	if span == nil {
+		return
+	}
+	ec := event.Context.AsV02()
+	if ec.Extensions == nil {
+		ec.Extensions = make(map[string]interface{})
+	}
+	ec.Extensions[tracing.TraceparentExtension] = span.Traceparent()
+	event.Context = ec
+}
+
+// sendResponder is a Responder that sends each reply with a Sender.
+type sendResponder struct {
+	ctx     context.Context
+	sender  *Sender
+	metrics *Metrics
+	tracer  *tracing.Tracer
+	name    string
+	req     *Request
+
+	// err is the last send failure, if any.
+	err error
+}
+
+var _ Responder = (*sendResponder)(nil)
+
+func (r *sendResponder) Reply(text string) {
+	r.ReplyMessage(events.Message{Text: text})
+}
+
+func (r *sendResponder) ReplyMessage(msg events.Message) {
+	if msg.Channel == "" {
+		msg.Channel = r.req.Channel
+	}
+	ctx, span := r.tracer.Start(r.ctx, "send "+r.name, tracing.SpanKindClient)
+	defer span.Finish()
+	event := ResponseEvent(r.name, r.req.Event, msg)
+	setTraceparent(&event, span)
+	start := time.Now()
+	err := r.sender.Send(ctx, event)
+	r.metrics.sent(r.name, start, err)
+	span.SetError(err)
+	if err != nil {
+		r.err = err
+		logging.FromContext(r.ctx).Errorf("failed to send response: %s", err)
+	} else {
+		logging.FromContext(r.ctx).Infof("sent response %q", msg.Text)
+	}
+}
+
+func (r *sendResponder) ReplyError(err error) {
+	logging.FromContext(r.ctx).Warnf("command failed: %s", err)
+	r.Reply(fmt.Sprintf("%s: %s", r.name, err))
+}
+
+// replyResponder is a Responder that sets the reply as the response of the
+// incoming event. Only one reply can be returned this way, later replies are
+// dropped.
+type replyResponder struct {
+	ctx     context.Context
+	metrics *Metrics
+	tracer  *tracing.Tracer
+	name    string
+	req     *Request
+	resp    *cloudevents.EventResponse
+}
+
+var _ Responder = (*replyResponder)(nil)
+
+func (r *replyResponder) Reply(text string) {
+	r.ReplyMessage(events.Message{Text: text})
+}
+
+func (r *replyResponder) ReplyMessage(msg events.Message) {
+	if msg.Channel == "" {
+		msg.Channel = r.req.Channel
+	}
+	_, span := r.tracer.Start(r.ctx, "send "+r.name, tracing.SpanKindClient)
+	defer span.Finish()
+	start := time.Now()
+	var err error
+	switch {
+	case r.resp == nil:
+		err = errors.New("response not supported")
+		logging.FromContext(r.ctx).Errorf("can not reply, %s", err)
+	case r.resp.Event != nil:
+		err = errors.New("already replied")
+		logging.FromContext(r.ctx).Warnf("%s, dropping response %q", err, msg.Text)
+	default:
+		event := ResponseEvent(r.name, r.req.Event, msg)
+		setTraceparent(&event, span)
+		r.resp.RespondWith(http.StatusOK, &event)
+		logging.FromContext(r.ctx).Infof("replied with response %q", msg.Text)
+	}
+	r.metrics.sent(r.name, start, err)
+	span.SetError(err)
+}
+
+func (r *replyResponder) ReplyError(err error) {
+	logging.FromContext(r.ctx).Warnf("command failed: %s", err)
+	r.Reply(fmt.Sprintf("%s: %s", r.name, err))
+}
@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"context"
+	"github.com/botless/commands/pkg/metrics"
+	"github.com/botless/commands/pkg/tracing"
+	"github.com/botless/events/pkg/events"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"testing"
+)
+
+// spans keeps the spans exported.
+type spans []*tracing.Span
+
+func (s *spans) Export(span *tracing.Span) {
+	*s = append(*s, span)
+}
+
+func TestReplyResponderRecords(t *testing.T) {
+	r := testRegistry(t)
+	err := r.Register(Command{
+		Name: "twice",
+		Handler: func(ctx context.Context, req *Request, resp Responder) {
+			resp.Reply("once")
+			resp.Reply("twice")
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register: %s", err)
+	}
+	exported := &spans{}
+	c := &Commands{
+		Registry: r,
+		Metrics:  NewMetrics(metrics.NewRegistry()),
+		Tracer:   &tracing.Tracer{Exporter: exported},
+	}
+
+	resp := &cloudevents.EventResponse{}
+	c.ReceiveAndReply(context.Background(), commandEvent(t, events.Command{Cmd: "echo", Args: "hi"}), resp)
+	if resp.Event == nil {
+		t.Fatalf("no reply")
+	}
+	var send *tracing.Span
+	for _, span := range *exported {
+		if span.Name == "send echo" {
+			send = span
+		}
+	}
+	if send == nil {
+		t.Fatalf("no send span in %d spans", len(*exported))
+	}
+	if got := traceparent(*resp.Event); got != send.Traceparent() {
+		t.Errorf("reply traceparent = %q, want the send span %q", got, send.Traceparent())
+	}
+	if got, want := c.Metrics.Counts("echo"), (CommandCounts{Handled: 1, SendSuccesses: 1}); got != want {
+		t.Errorf("Counts(echo) = %+v, want %+v", got, want)
+	}
+
+	// Only one reply can be returned, the second is a failed send.
+	c.ReceiveAndReply(context.Background(), commandEvent(t, events.Command{Cmd: "twice"}), &cloudevents.EventResponse{})
+	if got, want := c.Metrics.Counts("twice"), (CommandCounts{Handled: 1, SendSuccesses: 1, SendFailures: 1}); got != want {
+		t.Errorf("Counts(twice) = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"context"
+	"github.com/botless/commands/pkg/logging"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents/client"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+
+	// Extensions added to events forwarded to the dead letter sink.
+	deadLetterReasonExtension   = "deadletterreason"
+	deadLetterAttemptsExtension = "deadletterattempts"
+)
+
+// Sender delivers events with Ce, retrying failed sends with exponential
+// backoff and jitter. Events that can not be delivered are forwarded to
+// DeadLetter, if set.
+type Sender struct {
+	Ce client.Client
+
+	// MaxAttempts is the number of times a send is tried. Defaults to 1.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. Defaults to 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries. Defaults to 5s.
+	MaxBackoff time.Duration
+
+	// DeadLetter receives events that could not be delivered, with the
+	// failure reason and number of attempts added as extensions.
+	DeadLetter client.Client
+}
+
+// Send delivers event, returning the last error if all attempts failed.
+func (s *Sender) Send(ctx context.Context, event cloudevents.Event) error {
+	attempts := s.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+
+	var err error
+	attempt := 0
+	for {
+		attempt++
+		if _, err = s.Ce.Send(ctx, event); err == nil {
+			return nil
+		}
+		if attempt >= attempts || ctx.Err() != nil {
+			break
+		}
+		backoff := s.backoff(attempt)
+		logging.FromContext(ctx).Warnf("send attempt %d/%d failed, retrying in %s: %s", attempt, attempts, backoff, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+			continue
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		break
+	}
+
+	s.deadLetter(logging.FromContext(ctx), event, err, attempt)
+	return err
+}
+
+// backoff returns the delay before retry number attempt, a random duration
+// between half and all of InitialBackoff*2^(attempt-1), capped at MaxBackoff.
+func (s *Sender) backoff(attempt int) time.Duration {
+	initial := s.InitialBackoff
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	max := s.MaxBackoff
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	d := initial
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func (s *Sender) deadLetter(logger *logging.Logger, event cloudevents.Event, reason error, attempts int) {
+	if s.DeadLetter == nil {
+		return
+	}
+	ec := event.Context.AsV02()
+	extensions := make(map[string]interface{}, len(ec.Extensions)+2)
+	for k, v := range ec.Extensions {
+		extensions[k] = v
+	}
+	extensions[deadLetterReasonExtension] = reason.Error()
+	extensions[deadLetterAttemptsExtension] = attempts
+	ec.Extensions = extensions
+	event.Context = ec
+
+	// The context of the original send may be done, the dead letter still
+	// deserves a try.
+	if _, err := s.DeadLetter.Send(context.Background(), event); err != nil {
+		logger.Errorf("failed to send %s to dead letter sink: %s", event.Type(), err)
+	} else {
+		logger.Infof("sent undeliverable %s to dead letter sink", event.Type())
+	}
+}
@@ -0,0 +1,182 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failingClient fails the first failures sends, or every send when failures
+// is negative. It keeps the events it sent.
+type failingClient struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	sent     []cloudevents.Event
+
+	// cancel, when set, is called on every send, as if stopping meanwhile.
+	cancel context.CancelFunc
+}
+
+func (c *failingClient) Send(ctx context.Context, event cloudevents.Event) (*cloudevents.Event, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	if c.cancel != nil {
+		c.cancel()
+	}
+	if c.failures != 0 {
+		c.failures--
+		return nil, errors.New("unavailable")
+	}
+	c.sent = append(c.sent, event)
+	return nil, nil
+}
+
+func (c *failingClient) StartReceiver(ctx context.Context, fn interface{}) error {
+	return nil
+}
+
+func (c *failingClient) StopReceiver(ctx context.Context) error {
+	return nil
+}
+
+func testEvent() cloudevents.Event {
+	return cloudevents.Event{
+		Context: cloudevents.EventContextV02{
+			ID:         "1",
+			Type:       CommandType("echo"),
+			Source:     *types.ParseURLRef("//test"),
+			Extensions: map[string]interface{}{"origin": "slack"},
+		}.AsV02(),
+	}
+}
+
+func TestSenderAttempts(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		failures    int
+		wantCalls   int
+		wantErr     bool
+	}{
+		{"default is one attempt", 0, -1, 1, true},
+		{"succeeds at once", 3, 0, 1, false},
+		{"succeeds on retry", 3, 2, 3, false},
+		{"gives up", 3, -1, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ce := &failingClient{failures: tt.failures}
+			s := &Sender{Ce: ce, MaxAttempts: tt.maxAttempts, InitialBackoff: time.Millisecond}
+			err := s.Send(context.Background(), testEvent())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, want error %t", err, tt.wantErr)
+			}
+			if ce.calls != tt.wantCalls {
+				t.Errorf("sent %d times, want %d", ce.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestSenderStops(t *testing.T) {
+	// Cancelled while sending, there is no retry.
+	ctx, cancel := context.WithCancel(context.Background())
+	ce := &failingClient{failures: -1, cancel: cancel}
+	s := &Sender{Ce: ce, MaxAttempts: 5, InitialBackoff: time.Millisecond}
+	if err := s.Send(ctx, testEvent()); err == nil {
+		t.Errorf("Send() succeeded")
+	}
+	if ce.calls != 1 {
+		t.Errorf("sent %d times, want 1", ce.calls)
+	}
+
+	// Cancelled while backing off, the backoff is cut short.
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ce = &failingClient{failures: -1}
+	s = &Sender{Ce: ce, MaxAttempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+	start := time.Now()
+	if err := s.Send(ctx, testEvent()); err == nil {
+		t.Errorf("Send() succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Send() returned after %s, want it to stop with ctx", elapsed)
+	}
+	if ce.calls != 1 {
+		t.Errorf("sent %d times, want 1", ce.calls)
+	}
+}
+
+func TestSenderBackoff(t *testing.T) {
+	s := &Sender{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, d := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		3:  400 * time.Millisecond,
+		4:  800 * time.Millisecond,
+		5:  time.Second,
+		50: time.Second,
+	} {
+		for i := 0; i < 100; i++ {
+			if got := s.backoff(attempt); got < d/2 || got > d {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", attempt, got, d/2, d)
+			}
+		}
+	}
+
+	// Defaults.
+	s = &Sender{}
+	for i := 0; i < 100; i++ {
+		if got := s.backoff(1); got < defaultInitialBackoff/2 || got > defaultInitialBackoff {
+			t.Fatalf("default backoff(1) = %s", got)
+		}
+		if got := s.backoff(20); got < defaultMaxBackoff/2 || got > defaultMaxBackoff {
+			t.Fatalf("default backoff(20) = %s", got)
+		}
+	}
+}
+
+func TestSenderDeadLetter(t *testing.T) {
+	dl := &failingClient{}
+	s := &Sender{
+		Ce:             &failingClient{failures: -1},
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+		DeadLetter:     dl,
+	}
+	if err := s.Send(context.Background(), testEvent()); err == nil {
+		t.Fatalf("Send() succeeded")
+	}
+	if len(dl.sent) != 1 {
+		t.Fatalf("dead lettered %d events, want 1", len(dl.sent))
+	}
+	ec := dl.sent[0].Context.AsV02()
+	if ec.ID != "1" || ec.Type != CommandType("echo") {
+		t.Errorf("dead letter is %s %s, want the original event", ec.ID, ec.Type)
+	}
+	for k, want := range map[string]interface{}{
+		"origin":                    "slack",
+		deadLetterReasonExtension:   "unavailable",
+		deadLetterAttemptsExtension: 2,
+	} {
+		if got := ec.Extensions[k]; got != want {
+			t.Errorf("extension %s = %v, want %v", k, got, want)
+		}
+	}
+
+	// Delivered events are not dead lettered.
+	dl = &failingClient{}
+	s = &Sender{Ce: &failingClient{}, DeadLetter: dl}
+	if err := s.Send(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(dl.sent) != 0 {
+		t.Errorf("dead lettered %d events, want none", len(dl.sent))
+	}
+}
@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/botless/commands/pkg/logging"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxSuggestions is the number of commands offered for an unknown one.
+	maxSuggestions = 3
+	// maxSuggestionDistance is the largest edit distance still suggested.
+	maxSuggestionDistance = 2
+)
+
+// suggest replies to a command event of unknown type with the closest
+// registered commands. Only commands selected by the filter are suggested,
+// so only the service handling them answers; when none is close enough the
+// event is ignored.
+func (c *Commands) suggest(ctx context.Context, event cloudevents.Event, responder responderFunc) {
+	logger := c.eventLogger(event)
+	prefix := CommandType("")
+	if !strings.HasPrefix(event.Type(), prefix) {
+		return
+	}
+	name := strings.TrimPrefix(event.Type(), prefix)
+	suggestions := c.registry().closest(name, c.Filter)
+	if len(suggestions) == 0 {
+		return
+	}
+	if c.duplicate(ctx, event) {
+		c.Metrics.duplicateEvent(otherType)
+		logger.Infof("dropped duplicate event")
+		return
+	}
+	req := &Request{Event: event}
+	if err := event.DataAs(&req.Command); err != nil {
+		c.Metrics.decodeFailure(otherType)
+		logger.Errorf("failed to decode events.Command: %s", err)
+		return
+	}
+	logger = logger.With("channel", req.Channel, "author", req.Author)
+	ctx = logging.WithLogger(ctx, logger)
+	logger.Infof("suggesting %s for unknown command %q", strings.Join(suggestions, ", "), name)
+
+	quoted := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		quoted[i] = "`" + s + "`"
+	}
+	did := quoted[0]
+	if len(quoted) > 1 {
+		did = strings.Join(quoted[:len(quoted)-1], ", ") + " or " + quoted[len(quoted)-1]
+	}
+	responder(ctx, name, req).Reply(fmt.Sprintf("unknown command `%s`, did you mean %s?", name, did))
+}
+
+// closest returns the enabled command names and aliases selected by filter
+// that are within maxSuggestionDistance edits of name, closest first.
+func (r *Registry) closest(name string, filter *TypeFilter) []string {
+	name = strings.ToLower(name)
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	for _, cmd := range r.Commands() {
+		if !r.Enabled(cmd) || !filter.Match(cmd.Type) {
+			continue
+		}
+		for _, n := range append([]string{cmd.Name}, cmd.Aliases...) {
+			n = strings.ToLower(n)
+			d := editDistance(name, n)
+			// Short names are close to everything, require some overlap.
+			if d <= maxSuggestionDistance && d < len(n) && d < len([]rune(name)) {
+				candidates = append(candidates, candidate{name: n, distance: d})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+	var names []string
+	for _, c := range candidates {
+		if len(names) == maxSuggestions {
+			break
+		}
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// editDistance returns the optimal string alignment distance between a and b:
+// the number of insertions, deletions, substitutions and transpositions of
+// adjacent characters needed to turn a into b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+func minInt(v int, vs ...int) int {
+	for _, o := range vs {
+		if o < v {
+			v = o
+		}
+	}
+	return v
+}
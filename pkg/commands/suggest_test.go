@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"flip", "flip", 0},
+		{"", "flip", 4},
+		{"flip", "", 4},
+		{"flpi", "flip", 1},
+		{"fip", "flip", 1},
+		{"fliip", "flip", 1},
+		{"flop", "flip", 1},
+		{"ca", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"héllo", "hello", 1},
+		{"ëcho", "echo", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := editDistance(tt.b, tt.a); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestClosest(t *testing.T) {
+	r := testRegistry(t)
+	tests := []struct {
+		name   string
+		filter string
+		want   []string
+	}{
+		{name: "ehco", want: []string{"echo"}},
+		{name: "shuot", want: []string{"shout"}},
+		{name: "CAPS!", want: []string{"caps"}},
+		{name: "cap", want: []string{"caps"}},
+		{name: "hlep", want: []string{"help"}},
+		{name: "xyz", want: nil},
+		// Short names need some overlap.
+		{name: "ec", want: nil},
+		{name: "ech", want: []string{"echo"}},
+		{name: "e", want: nil},
+		{name: "ehco", filter: CommandType("caps"), want: nil},
+		{name: "shuot", filter: CommandType("caps"), want: []string{"shout"}},
+	}
+	for _, tt := range tests {
+		got := r.closest(tt.name, mustFilter(t, tt.filter))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("closest(%q, %q) = %q, want %q", tt.name, tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	c := &Commands{
+		Registry: testRegistry(t),
+		Suggest:  true,
+	}
+	if got, want := reply(t, c, "c1", "ehco hi"), "unknown command `ehco`, did you mean `echo`?"; got != want {
+		t.Errorf("suggestion = %q, want %q", got, want)
+	}
+	if got := reply(t, c, "c1", "zzzzzz hi"); got != "" {
+		t.Errorf("suggestion for a distant name = %q, want none", got)
+	}
+	c.Suggest = false
+	if got := reply(t, c, "c1", "ehco hi"); got != "" {
+		t.Errorf("suggestion without Suggest = %q, want none", got)
+	}
+}
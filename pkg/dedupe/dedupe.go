@@ -0,0 +1,128 @@
+// Package dedupe remembers which events have been handled so redeliveries
+// can be dropped.
+package dedupe
+
+import (
+	"container/list"
+	"context"
+	"github.com/botless/commands/pkg/store"
+	"sync"
+	"time"
+)
+
+// Store records keys for a limited time. Implementations backed by a shared
+// store let replicas dedupe across each other.
+type Store interface {
+	// Seen marks key as seen for ttl and reports whether it was already
+	// marked and not yet expired.
+	Seen(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Forget unmarks key, so the next Seen for it returns false.
+	Forget(ctx context.Context, key string) error
+}
+
+const defaultLRUSize = 10000
+
+// LRU is an in memory Store holding at most a fixed number of keys, evicting
+// the least recently marked when full.
+type LRU struct {
+	size int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+
+	// now returns the time TTLs are measured against.
+	now func() time.Time
+}
+
+var _ Store = (*LRU)(nil)
+
+type entry struct {
+	key     string
+	expires time.Time
+}
+
+// NewLRU returns an LRU holding up to size keys. A size of 0 or less holds
+// 10000 keys.
+func NewLRU(size int) *LRU {
+	if size <= 0 {
+		size = defaultLRUSize
+	}
+	return &LRU{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+		now:   time.Now,
+	}
+}
+
+func (l *LRU) Seen(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.items[key]; ok {
+		e := el.Value.(*entry)
+		if now.Before(e.expires) {
+			return true, nil
+		}
+		e.expires = now.Add(ttl)
+		l.ll.MoveToFront(el)
+		return false, nil
+	}
+
+	l.items[key] = l.ll.PushFront(&entry{key: key, expires: now.Add(ttl)})
+	for l.ll.Len() > l.size {
+		oldest := l.ll.Back()
+		l.ll.Remove(oldest)
+		delete(l.items, oldest.Value.(*entry).key)
+	}
+	return false, nil
+}
+
+func (l *LRU) Forget(ctx context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		l.ll.Remove(el)
+		delete(l.items, key)
+	}
+	return nil
+}
+
+// Len returns the number of keys held, including expired keys not yet
+// evicted.
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ll.Len()
+}
+
+// storeNamespace is the namespace of a Shared store holding the keys.
+const storeNamespace = "dedupe"
+
+// Shared is a Store backed by a store.Store, so replicas sharing it dedupe
+// across each other.
+type Shared struct {
+	s store.Store
+}
+
+var _ Store = (*Shared)(nil)
+
+// NewShared returns a Shared keeping keys in s.
+func NewShared(s store.Store) *Shared {
+	return &Shared{s: s}
+}
+
+func (sh *Shared) Seen(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	set, err := sh.s.SetIfAbsent(ctx, storeNamespace, key, nil, ttl)
+	if err != nil {
+		return false, err
+	}
+	return !set, nil
+}
+
+func (sh *Shared) Forget(ctx context.Context, key string) error {
+	return sh.s.Delete(ctx, storeNamespace, key)
+}
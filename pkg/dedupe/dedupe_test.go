@@ -0,0 +1,101 @@
+package dedupe
+
+import (
+	"context"
+	"github.com/botless/commands/pkg/store"
+	"testing"
+	"time"
+)
+
+func TestLRUSeen(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	l := NewLRU(10)
+	l.now = func() time.Time { return now }
+
+	seen := func(key string) bool {
+		t.Helper()
+		s, err := l.Seen(ctx, key, time.Minute)
+		if err != nil {
+			t.Fatalf("Seen(%q) failed: %s", key, err)
+		}
+		return s
+	}
+
+	if seen("a") {
+		t.Errorf("first Seen(a) = true")
+	}
+	if !seen("a") {
+		t.Errorf("second Seen(a) = false")
+	}
+	if seen("b") {
+		t.Errorf("first Seen(b) = true")
+	}
+
+	now = now.Add(time.Minute)
+	if seen("a") {
+		t.Errorf("Seen(a) after its ttl = true")
+	}
+	if !seen("a") {
+		t.Errorf("Seen(a) after being marked again = false")
+	}
+
+	if err := l.Forget(ctx, "a"); err != nil {
+		t.Fatalf("Forget failed: %s", err)
+	}
+	if seen("a") {
+		t.Errorf("Seen(a) after Forget = true")
+	}
+}
+
+func TestLRUEvicts(t *testing.T) {
+	ctx := context.Background()
+	l := NewLRU(2)
+	for _, key := range []string{"a", "b", "c"} {
+		if _, err := l.Seen(ctx, key, time.Hour); err != nil {
+			t.Fatalf("Seen(%q) failed: %s", key, err)
+		}
+	}
+	if got := l.Len(); got != 2 {
+		t.Errorf("Len() = %d, want 2", got)
+	}
+	if s, _ := l.Seen(ctx, "a", time.Hour); s {
+		t.Errorf("least recently marked key was not evicted")
+	}
+	if s, _ := l.Seen(ctx, "c", time.Hour); !s {
+		t.Errorf("recently marked key was evicted")
+	}
+}
+
+func TestSharedSeen(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	// Two replicas sharing s.
+	a, b := NewShared(s), NewShared(s)
+
+	seen := func(d Store, key string) bool {
+		t.Helper()
+		s, err := d.Seen(ctx, key, time.Minute)
+		if err != nil {
+			t.Fatalf("Seen(%q) failed: %s", key, err)
+		}
+		return s
+	}
+
+	if seen(a, "e1") {
+		t.Errorf("first Seen(e1) = true")
+	}
+	if !seen(b, "e1") {
+		t.Errorf("Seen(e1) on another replica = false")
+	}
+	if seen(b, "e2") {
+		t.Errorf("first Seen(e2) = true")
+	}
+
+	if err := b.Forget(ctx, "e1"); err != nil {
+		t.Fatalf("Forget failed: %s", err)
+	}
+	if seen(a, "e1") {
+		t.Errorf("Seen(e1) after Forget = true")
+	}
+}
@@ -0,0 +1,223 @@
+// Package logging is a small leveled logger writing text or JSON lines with
+// structured fields.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+func (l Level) String() string {
+	switch l {
+	case Debug:
+		return "debug"
+	case Warn:
+		return "warn"
+	case Error:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// ParseLevel parses "debug", "info", "warn" or "error".
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return Debug, nil
+	case "", "info":
+		return Info, nil
+	case "warn", "warning":
+		return Warn, nil
+	case "error":
+		return Error, nil
+	default:
+		return Info, fmt.Errorf("unknown log level %q", s)
+	}
+}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+// Logger writes leveled lines carrying a set of fields. Loggers returned by
+// With share the output of their parent.
+type Logger struct {
+	out    *output
+	fields []field
+}
+
+type output struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level Level
+	json  bool
+}
+
+// New returns a Logger writing lines at or above level to w, as JSON objects
+// if json is set or as "time level message key=value..." otherwise.
+func New(w io.Writer, level Level, json bool) *Logger {
+	return &Logger{
+		out: &output{w: w, level: level, json: json},
+	}
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultLogger = New(os.Stderr, Info, false)
+)
+
+// Default returns the process wide logger.
+func Default() *Logger {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultLogger
+}
+
+// SetDefault replaces the process wide logger.
+func SetDefault(l *Logger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLogger = l
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx holding l.
+func WithLogger(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger in ctx, or Default.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return l
+	}
+	return Default()
+}
+
+// With returns a logger adding the given key value pairs to every line.
+// Empty values are skipped.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := append([]field(nil), l.fields...)
+	for i := 0; i+1 < len(kv); i += 2 {
+		if s, ok := kv[i+1].(string); ok && s == "" {
+			continue
+		}
+		fields = append(fields, field{key: fmt.Sprint(kv[i]), value: kv[i+1]})
+	}
+	return &Logger{out: l.out, fields: fields}
+}
+
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.out.level
+}
+
+func (l *Logger) Debugf(format string, a ...interface{}) {
+	l.log(Debug, format, a...)
+}
+
+func (l *Logger) Infof(format string, a ...interface{}) {
+	l.log(Info, format, a...)
+}
+
+func (l *Logger) Warnf(format string, a ...interface{}) {
+	l.log(Warn, format, a...)
+}
+
+func (l *Logger) Errorf(format string, a ...interface{}) {
+	l.log(Error, format, a...)
+}
+
+func (l *Logger) log(level Level, format string, a ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	msg := fmt.Sprintf(format, a...)
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	var buf bytes.Buffer
+	if l.out.json {
+		buf.WriteString(`{"time":`)
+		buf.WriteString(strconv.Quote(now))
+		buf.WriteString(`,"level":`)
+		buf.WriteString(strconv.Quote(level.String()))
+		buf.WriteString(`,"msg":`)
+		writeJSON(&buf, msg)
+		for _, f := range l.fields {
+			buf.WriteByte(',')
+			writeJSON(&buf, f.key)
+			buf.WriteByte(':')
+			writeJSON(&buf, f.value)
+		}
+		buf.WriteString("}\n")
+	} else {
+		buf.WriteString(now)
+		buf.WriteByte(' ')
+		buf.WriteString(strings.ToUpper(level.String()))
+		buf.WriteByte(' ')
+		buf.WriteString(msg)
+		for _, f := range l.fields {
+			buf.WriteByte(' ')
+			buf.WriteString(f.key)
+			buf.WriteByte('=')
+			v := fmt.Sprint(f.value)
+			if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+				v = strconv.Quote(v)
+			}
+			buf.WriteString(v)
+		}
+		buf.WriteByte('\n')
+	}
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w.Write(buf.Bytes())
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(v))
+	}
+	buf.Write(b)
+}
+
+// Writer returns an io.Writer logging each line written to it at level, for
+// use with the standard library log package.
+func (l *Logger) Writer(level Level) io.Writer {
+	return &lineWriter{l: l, level: level}
+}
+
+type lineWriter struct {
+	l     *Logger
+	level Level
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.l.log(w.level, "%s", line)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// timestamps matches the time lines start with, which varies.
+var timestamps = regexp.MustCompile(`(?m)^(\{"time":")?[0-9T:.Z-]+("?)`)
+
+func lines(buf *bytes.Buffer) string {
+	return timestamps.ReplaceAllString(buf.String(), "${1}TIME${2}")
+}
+
+func TestText(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New(buf, Info, false).With("event", "1", "empty", "", "channel", "C0123")
+	l.Debugf("hidden")
+	l.Infof("handled %s", "echo")
+	l.With("reason", `a "quoted" value`, "blank", nil, "n", 3).Warnf("slow")
+	l.Errorf("failed: %s", errors.New("boom"))
+
+	want := `TIME INFO handled echo event=1 channel=C0123
+TIME WARN slow event=1 channel=C0123 reason="a \"quoted\" value" blank=<nil> n=3
+TIME ERROR failed: boom event=1 channel=C0123
+`
+	if got := lines(buf); got != want {
+		t.Errorf("lines =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New(buf, Warn, true).With("event", "1", "empty", "")
+	l.Infof("hidden")
+	l.With("err", errors.New("boom"), "took", 1.5).Warnf("say %q", "hi")
+	l.Errorf("multi\nline")
+
+	want := `{"time":"TIME","level":"warn","msg":"say \"hi\"","event":"1","err":"boom","took":1.5}
+{"time":"TIME","level":"error","msg":"multi\nline","event":"1"}
+`
+	if got := lines(buf); got != want {
+		t.Errorf("lines =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTimeFormat(t *testing.T) {
+	buf := &bytes.Buffer{}
+	New(buf, Info, false).Infof("now")
+	stamp := bytes.SplitN(buf.Bytes(), []byte(" "), 2)[0]
+	if _, err := time.Parse(time.RFC3339Nano, string(stamp)); err != nil {
+		t.Errorf("time %q is not RFC 3339: %s", stamp, err)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Level
+		err  bool
+	}{
+		{"", Info, false},
+		{"debug", Debug, false},
+		{" INFO ", Info, false},
+		{"warning", Warn, false},
+		{"error", Error, false},
+		{"verbose", Info, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.s)
+		if got != tt.want || (err != nil) != tt.err {
+			t.Errorf("ParseLevel(%q) = %s, %v, want %s, error %t", tt.s, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := New(buf, Info, false).Writer(Error)
+	w.Write([]byte("first\nsecond\n"))
+	want := "TIME ERROR first\nTIME ERROR second\n"
+	if got := lines(buf); got != want {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
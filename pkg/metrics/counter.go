@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Counter is a value that only goes up.
+type Counter struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add increases the counter by d, which must not be negative.
+func (c *Counter) Add(d float64) {
+	if d < 0 {
+		panic("counter can not decrease")
+	}
+	c.mu.Lock()
+	c.value += d
+	c.mu.Unlock()
+}
+
+func (c *Counter) Value() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+// CounterVec is a set of counters partitioned by label values.
+type CounterVec struct {
+	vec *vec
+}
+
+// NewCounterVec registers a counter with the given label names.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{vec: newVec(name, help, "counter", labels)}
+	r.register(name, c)
+	return c
+}
+
+// With returns the counter for the given label values, in the order the
+// labels were declared.
+func (c *CounterVec) With(values ...string) *Counter {
+	return c.vec.child(values, func() interface{} { return &Counter{} }).(*Counter)
+}
+
+// Value returns the value of the counter for the given label values without
+// creating it.
+func (c *CounterVec) Value(values ...string) float64 {
+	if child, ok := c.vec.lookup(values).(*Counter); ok {
+		return child.Value()
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.vec.header(w)
+	c.vec.each(func(labels string, child interface{}) {
+		fmt.Fprintf(w, "%s%s %s\n", c.vec.name, labels, formatFloat(child.(*Counter).Value()))
+	})
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	mu    sync.Mutex
+	value float64
+	name  string
+	help  string
+}
+
+// NewGauge registers a gauge without labels.
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	r.register(name, g)
+	return g
+}
+
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *Gauge) Add(d float64) {
+	g.mu.Lock()
+	g.value += d
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
+	g.value = v
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+func (g *Gauge) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", g.name, g.help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", g.name)
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.Value()))
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// ObserveSince observes the seconds elapsed since start.
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// HistogramVec is a set of histograms partitioned by label values.
+type HistogramVec struct {
+	vec     *vec
+	buckets []float64
+}
+
+// NewHistogramVec registers a histogram with the given upper bounds and label
+// names. A nil buckets uses DefBuckets.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &HistogramVec{
+		vec:     newVec(name, help, "histogram", labels),
+		buckets: buckets,
+	}
+	r.register(name, h)
+	return h
+}
+
+// With returns the histogram for the given label values, in the order the
+// labels were declared.
+func (h *HistogramVec) With(values ...string) *Histogram {
+	return h.vec.child(values, func() interface{} {
+		return &Histogram{
+			buckets: h.buckets,
+			counts:  make([]uint64, len(h.buckets)),
+		}
+	}).(*Histogram)
+}
+
+// Count returns the number of observations of the histogram for the given
+// label values without creating it.
+func (h *HistogramVec) Count(values ...string) uint64 {
+	if child, ok := h.vec.lookup(values).(*Histogram); ok {
+		return child.Count()
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.vec.header(w)
+	h.vec.each(func(labels string, child interface{}) {
+		c := child.(*Histogram)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, b := range c.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.vec.name, withLabel(labels, "le", formatFloat(b)), c.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.vec.name, withLabel(labels, "le", formatFloat(math.Inf(1))), c.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.vec.name, labels, formatFloat(c.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.vec.name, labels, c.count)
+	})
+}
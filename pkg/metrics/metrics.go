@@ -0,0 +1,157 @@
+// Package metrics implements the counters, gauges and histograms botless
+// commands expose, rendered in the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default histogram buckets, in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type collector interface {
+	write(w io.Writer)
+}
+
+// Registry holds metrics and serves them over HTTP.
+type Registry struct {
+	mu         sync.Mutex
+	names      map[string]bool
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		names: make(map[string]bool),
+	}
+}
+
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic(fmt.Sprintf("metric %q already registered", name))
+	}
+	r.names[name] = true
+	r.collectors = append(r.collectors, c)
+}
+
+// ServeHTTP writes all metrics in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// vec holds one child per unique combination of label values.
+type vec struct {
+	mu       sync.Mutex
+	name     string
+	help     string
+	kind     string
+	labels   []string
+	children map[string]interface{}
+	values   map[string][]string
+}
+
+func newVec(name, help, kind string, labels []string) *vec {
+	return &vec{
+		name:     name,
+		help:     help,
+		kind:     kind,
+		labels:   labels,
+		children: make(map[string]interface{}),
+		values:   make(map[string][]string),
+	}
+}
+
+func (v *vec) child(values []string, create func() interface{}) interface{} {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metric %q expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c, ok := v.children[key]
+	if !ok {
+		c = create()
+		v.children[key] = c
+		v.values[key] = append([]string(nil), values...)
+	}
+	return c
+}
+
+// lookup returns the child for values, or nil if it was never created.
+func (v *vec) lookup(values []string) interface{} {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.children[strings.Join(values, "\xff")]
+}
+
+// each calls fn for every child in a stable order.
+func (v *vec) each(fn func(labels string, child interface{})) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.children))
+	for k := range v.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	children := make([]interface{}, len(keys))
+	labels := make([]string, len(keys))
+	for i, k := range keys {
+		children[i] = v.children[k]
+		labels[i] = formatLabels(v.labels, v.values[k])
+	}
+	v.mu.Unlock()
+	for i := range keys {
+		fn(labels[i], children[i])
+	}
+}
+
+func (v *vec) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, v.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, n := range names {
+		pairs[i] = n + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func withLabel(labels, name, value string) string {
+	pair := name + `="` + labelEscaper.Replace(value) + `"`
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+	events := r.NewCounterVec("events_total", "Events received.", "type", "result")
+	latency := r.NewHistogramVec("latency_seconds", "Handling latency.", []float64{1, 0.1}, "command")
+	inflight := r.NewGauge("inflight", "Events in flight.")
+	plain := r.NewCounterVec("plain_total", "Without labels.")
+
+	events.With("echo", "ok").Inc()
+	events.With("echo", "ok").Add(2)
+	events.With(`quote"back\slash`, "new\nline").Inc()
+	latency.With("echo").Observe(0.05)
+	latency.With("echo").Observe(0.5)
+	latency.With("echo").Observe(3)
+	latency.With("flip").Observe(0.1)
+	inflight.Set(2)
+	inflight.Dec()
+	plain.With().Add(1.5)
+
+	want := `# HELP events_total Events received.
+# TYPE events_total counter
+events_total{type="echo",result="ok"} 3
+events_total{type="quote\"back\\slash",result="new\nline"} 1
+# HELP latency_seconds Handling latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{command="echo",le="0.1"} 1
+latency_seconds_bucket{command="echo",le="1"} 2
+latency_seconds_bucket{command="echo",le="+Inf"} 3
+latency_seconds_sum{command="echo"} 3.55
+latency_seconds_count{command="echo"} 3
+latency_seconds_bucket{command="flip",le="0.1"} 1
+latency_seconds_bucket{command="flip",le="1"} 1
+latency_seconds_bucket{command="flip",le="+Inf"} 1
+latency_seconds_sum{command="flip"} 0.1
+latency_seconds_count{command="flip"} 1
+# HELP inflight Events in flight.
+# TYPE inflight gauge
+inflight 1
+# HELP plain_total Without labels.
+# TYPE plain_total counter
+plain_total 1.5
+`
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if got := w.Body.String(); got != want {
+		t.Errorf("metrics =\n%s\nwant\n%s", got, want)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/plain; version=0.0.4" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := events.Value("echo", "ok"); got != 3 {
+		t.Errorf("Value() = %g, want 3", got)
+	}
+	if got := latency.Count("missing"); got != 0 {
+		t.Errorf("Count() of a missing histogram = %d, want 0", got)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	r := NewRegistry()
+	r.NewGauge("up", "Up.")
+	defer func() {
+		if recover() == nil {
+			t.Errorf("registering a name twice did not panic")
+		}
+	}()
+	r.NewCounterVec("up", "Up again.")
+}
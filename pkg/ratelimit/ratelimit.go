@@ -0,0 +1,147 @@
+// Package ratelimit implements keyed token bucket rate limiting.
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit allows Events per Per, with bursts of up to Events. The zero Limit
+// allows everything.
+type Limit struct {
+	Events int
+	Per    time.Duration
+}
+
+// Unlimited reports whether l allows everything.
+func (l Limit) Unlimited() bool {
+	return l.Events <= 0 || l.Per <= 0
+}
+
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return ""
+	}
+	return fmt.Sprintf("%d/%s", l.Events, l.Per)
+}
+
+// interval is the time to earn back one token.
+func (l Limit) interval() time.Duration {
+	return l.Per / time.Duration(l.Events)
+}
+
+// ParseLimit parses "<events>/<duration>", e.g. "5/1m". An empty string is
+// the unlimited Limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Limit{}, nil
+	}
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("invalid limit %q, expected <events>/<duration>", s)
+	}
+	events, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || events <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q, events must be a positive integer", s)
+	}
+	per, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || per <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q, duration must be positive", s)
+	}
+	return Limit{Events: events, Per: per}, nil
+}
+
+// Decision is the result of asking a Limiter to allow an event.
+type Decision struct {
+	Allowed bool
+	// RetryAfter is how long until the next event would be allowed.
+	RetryAfter time.Duration
+	// FirstDenied is set on the first denial since the key was last allowed,
+	// so callers can notify once per window instead of on every event.
+	FirstDenied bool
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	denied bool
+}
+
+// Limiter applies a Limit to each key separately.
+type Limiter struct {
+	limit Limit
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	sweep   time.Time
+
+	// now is the clock, set to a fake one by the tests.
+	now func() time.Time
+}
+
+func New(limit Limit) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Limit returns the limit applied by l.
+func (l *Limiter) Limit() Limit {
+	return l.limit
+}
+
+// Allow takes a token for key if one is available.
+func (l *Limiter) Allow(key string) Decision {
+	if l.limit.Unlimited() {
+		return Decision{Allowed: true}
+	}
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.evict(now)
+
+	burst := float64(l.limit.Events)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens += float64(now.Sub(b.last)) / float64(l.limit.interval())
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		b.denied = false
+		return Decision{Allowed: true}
+	}
+	d := Decision{
+		RetryAfter:  time.Duration((1 - b.tokens) * float64(l.limit.interval())),
+		FirstDenied: !b.denied,
+	}
+	b.denied = true
+	return d
+}
+
+// evict drops buckets that have been idle long enough to be full again, at
+// most once per Per.
+func (l *Limiter) evict(now time.Time) {
+	if now.Sub(l.sweep) < l.limit.Per {
+		return
+	}
+	l.sweep = now
+	for k, b := range l.buckets {
+		if now.Sub(b.last) >= l.limit.Per {
+			delete(l.buckets, k)
+		}
+	}
+}
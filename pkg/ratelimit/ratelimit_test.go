@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Limit
+		err  bool
+	}{
+		{in: "", want: Limit{}},
+		{in: " ", want: Limit{}},
+		{in: "5/1m", want: Limit{Events: 5, Per: time.Minute}},
+		{in: " 10 / 30s ", want: Limit{Events: 10, Per: 30 * time.Second}},
+		{in: "5", err: true},
+		{in: "0/1m", err: true},
+		{in: "-1/1m", err: true},
+		{in: "x/1m", err: true},
+		{in: "5/soon", err: true},
+		{in: "5/0s", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseLimit(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseLimit(%q) unexpected error: %s", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLimit(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// fakeClock is a clock advanced only by the test.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func TestAllow(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	l := New(Limit{Events: 2, Per: time.Minute})
+	l.now = clock.now
+
+	for i := 0; i < 2; i++ {
+		if d := l.Allow("a"); !d.Allowed {
+			t.Fatalf("event %d of the burst denied", i+1)
+		}
+	}
+	d := l.Allow("a")
+	if d.Allowed {
+		t.Fatalf("event over the burst allowed")
+	}
+	if !d.FirstDenied {
+		t.Errorf("first denial not reported as FirstDenied")
+	}
+	if d.RetryAfter != 30*time.Second {
+		t.Errorf("RetryAfter = %s, want 30s", d.RetryAfter)
+	}
+	if d := l.Allow("a"); d.Allowed || d.FirstDenied {
+		t.Errorf("second denial = %+v, want denied and not FirstDenied", d)
+	}
+	if d := l.Allow("b"); !d.Allowed {
+		t.Errorf("other key denied")
+	}
+
+	clock.advance(30 * time.Second)
+	if d := l.Allow("a"); !d.Allowed {
+		t.Errorf("event after earning a token back denied")
+	}
+	if d := l.Allow("a"); d.Allowed || !d.FirstDenied {
+		t.Errorf("denial after being allowed = %+v, want FirstDenied", d)
+	}
+
+	// Idle buckets are full again and evicted.
+	clock.advance(time.Hour)
+	if d := l.Allow("b"); !d.Allowed {
+		t.Errorf("event after idling denied")
+	}
+	if _, ok := l.buckets["a"]; ok {
+		t.Errorf("idle bucket was not evicted")
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	l := New(Limit{})
+	for i := 0; i < 100; i++ {
+		if d := l.Allow("a"); !d.Allowed {
+			t.Fatalf("unlimited limiter denied event %d", i+1)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/botless/commands/pkg/logging"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// ErrClosed is returned when using a File store after Close.
+	ErrClosed = errors.New("store is closed")
+	// ErrLocked is returned by OpenFile when another File, in this or
+	// another process, has the file open.
+	ErrLocked = errors.New("store is locked by another process")
+)
+
+// compactAfter is the number of obsolete records the log of a File may hold
+// before it is rewritten.
+const compactAfter = 1000
+
+// File is a Store kept in memory and persisted to an append only log on disk.
+// Every write is synced before it is applied, and the log is compacted to the
+// live entries when opened and as it grows. A lock file next to the log
+// ensures only one File has it open at a time, so replicas share a File by
+// serving it with Handler.
+type File struct {
+	path string
+	t    *table
+	lock *os.File
+
+	f       *os.File
+	w       *bufio.Writer
+	records int
+}
+
+var _ Store = (*File)(nil)
+
+// record is a line of the log.
+type record struct {
+	Op        string `json:"op"`
+	Namespace string `json:"ns"`
+	Key       string `json:"key"`
+	Value     []byte `json:"value,omitempty"`
+	// Expires is in Unix nanoseconds, 0 if the entry does not expire.
+	Expires int64 `json:"expires,omitempty"`
+}
+
+const (
+	opSet    = "set"
+	opDelete = "del"
+)
+
+// OpenFile opens the store persisted at path, creating it and its directory
+// if needed. It fails with ErrLocked while another File has path open.
+func OpenFile(path string) (*File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	lock, err := lockFile(path + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	s := &File{path: path, t: newTable(), lock: lock}
+	if err := s.replay(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	s.t.sweep()
+	if err := s.compact(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// replay loads the entries of the log. A partially written last line, left
+// by a crash, is ignored.
+func (s *File) replay() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	var bad error
+	line := 0
+	for scanner.Scan() {
+		line++
+		if bad != nil {
+			return bad
+		}
+		r := record{}
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			bad = fmt.Errorf("%s:%d: %s", s.path, line, err)
+			continue
+		}
+		s.apply(r)
+	}
+	return scanner.Err()
+}
+
+func (s *File) apply(r record) {
+	switch r.Op {
+	case opSet:
+		e := Entry{Key: r.Key, Value: r.Value}
+		if r.Expires != 0 {
+			e.Expires = time.Unix(0, r.Expires)
+		}
+		s.t.put(r.Namespace, e)
+	case opDelete:
+		s.t.remove(r.Namespace, r.Key)
+	}
+}
+
+func setRecord(ns string, e Entry) record {
+	r := record{Op: opSet, Namespace: ns, Key: e.Key, Value: e.Value}
+	if !e.Expires.IsZero() {
+		r.Expires = e.Expires.UnixNano()
+	}
+	return r
+}
+
+// write appends r to the log and syncs it, then applies it.
+func (s *File) write(r record) error {
+	if s.f == nil {
+		return ErrClosed
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := s.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	if err := s.f.Sync(); err != nil {
+		return err
+	}
+	s.apply(r)
+	s.records++
+	if s.records > s.live()+compactAfter {
+		// r is already stored, a log that could not be compacted is still
+		// appended to and compaction tried again on the next write.
+		if err := s.compact(); err != nil {
+			logging.Default().Errorf("failed to compact %s: %s", s.path, err)
+		}
+	}
+	return nil
+}
+
+func (s *File) live() int {
+	n := 0
+	for _, entries := range s.t.items {
+		n += len(entries)
+	}
+	return n
+}
+
+// compact rewrites the log with only the live entries and replaces the log
+// with it. The log is left as it was when compact fails.
+func (s *File) compact() error {
+	s.t.sweep()
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	failed := func(err error) error {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	records := 0
+	for ns, entries := range s.t.items {
+		for _, e := range entries {
+			if err := enc.Encode(setRecord(ns, e)); err != nil {
+				return failed(err)
+			}
+			records++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return failed(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return failed(err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return failed(err)
+	}
+
+	// The rewritten log is appended to through tmp, which is at its end, so
+	// there is no reopening left to fail.
+	if s.f != nil {
+		s.f.Close()
+	}
+	s.f, s.w, s.records = tmp, bufio.NewWriter(tmp), records
+	return nil
+}
+
+func (s *File) Get(ctx context.Context, ns, key string) ([]byte, bool, error) {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	if s.f == nil {
+		return nil, false, ErrClosed
+	}
+	e, ok := s.t.get(ns, key)
+	if !ok {
+		return nil, false, nil
+	}
+	return copyEntry(e).Value, true, nil
+}
+
+func (s *File) Set(ctx context.Context, ns, key string, value []byte, ttl time.Duration) error {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	return s.write(setRecord(ns, copyEntry(Entry{Key: key, Value: value, Expires: s.t.expiry(ttl)})))
+}
+
+func (s *File) SetIfAbsent(ctx context.Context, ns, key string, value []byte, ttl time.Duration) (bool, error) {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	if s.f == nil {
+		return false, ErrClosed
+	}
+	if _, ok := s.t.get(ns, key); ok {
+		return false, nil
+	}
+	if err := s.write(setRecord(ns, copyEntry(Entry{Key: key, Value: value, Expires: s.t.expiry(ttl)}))); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *File) Delete(ctx context.Context, ns, key string) error {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	return s.write(record{Op: opDelete, Namespace: ns, Key: key})
+}
+
+func (s *File) Incr(ctx context.Context, ns, key string, delta int64) (int64, error) {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	if s.f == nil {
+		return 0, ErrClosed
+	}
+	e, n, err := s.t.incr(ns, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.write(setRecord(ns, e)); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *File) Scan(ctx context.Context, ns, prefix string) ([]Entry, error) {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	if s.f == nil {
+		return nil, ErrClosed
+	}
+	return s.t.scan(ns, prefix), nil
+}
+
+func (s *File) Close() error {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	// Unlock only once the log is closed, so the next File sees all writes.
+	if lerr := s.lock.Close(); err == nil {
+		err = lerr
+	}
+	return err
+}
@@ -0,0 +1,171 @@
+package store_test
+
+import (
+	"context"
+	"github.com/botless/commands/pkg/logging"
+	"github.com/botless/commands/pkg/store"
+	"github.com/botless/commands/pkg/store/storetest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	return dir
+}
+
+func TestFileConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		dir := tempDir(t)
+		// The store is closed by Run before the directory is removed.
+		s, err := store.OpenFile(filepath.Join(dir, "state", "store.log"))
+		if err != nil {
+			os.RemoveAll(dir)
+			t.Fatalf("OpenFile: %v", err)
+		}
+		return &removing{File: s, dir: dir}
+	})
+}
+
+// removing removes the directory of a File store when it is closed.
+type removing struct {
+	*store.File
+	dir string
+}
+
+func (r *removing) Close() error {
+	defer os.RemoveAll(r.dir)
+	return r.File.Close()
+}
+
+func TestFileReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "store.log")
+
+	s, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if err := s.Set(ctx, "ns", "kept", []byte("value"), 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := s.Set(ctx, "ns", "deleted", []byte("value"), 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := s.Delete(ctx, "ns", "deleted"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Incr(ctx, "ns", "counter", 3); err != nil {
+		t.Fatalf("Incr: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := s.Set(ctx, "ns", "closed", []byte("value"), 0); err != store.ErrClosed {
+		t.Errorf("Set after Close = %v, want ErrClosed", err)
+	}
+
+	// A partially written last record, left by a crash, is ignored.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	f.WriteString(`{"op":"set","ns":"ns","key":"torn"`)
+	f.Close()
+
+	s, err = store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer s.Close()
+	entries, err := s.Scan(ctx, "ns", "")
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	got := map[string]string{}
+	for _, e := range entries {
+		got[e.Key] = string(e.Value)
+	}
+	if len(got) != 2 || got["kept"] != "value" || got["counter"] != "3" {
+		t.Errorf("entries after reopening = %v, want kept and counter", got)
+	}
+}
+
+func TestFileLocked(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "store.log")
+
+	s, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if other, err := store.OpenFile(path); err != store.ErrLocked {
+		if other != nil {
+			other.Close()
+		}
+		t.Fatalf("second OpenFile = %v, want ErrLocked", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	s, err = store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile after Close: %v", err)
+	}
+	s.Close()
+}
+
+func TestFileCompactionFails(t *testing.T) {
+	defer logging.SetDefault(logging.Default())
+	logging.SetDefault(logging.New(ioutil.Discard, logging.Error, false))
+	ctx := context.Background()
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	state := filepath.Join(dir, "state")
+	path := filepath.Join(state, "store.log")
+
+	s, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	defer s.Close()
+	// Without its directory the log can not be rewritten, but it can still
+	// be appended to.
+	if err := os.RemoveAll(state); err != nil {
+		t.Fatal(err)
+	}
+	const n = 1100
+	for i := 0; i < n; i++ {
+		if _, err := s.Incr(ctx, "ns", "counter", 1); err != nil {
+			t.Fatalf("Incr %d: %v", i, err)
+		}
+	}
+
+	// Once the directory is back the next write compacts the log to it.
+	if err := os.Mkdir(state, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Incr(ctx, "ns", "counter", 1); err != nil {
+		t.Fatalf("Incr: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	s, err = store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer s.Close()
+	if value, _, err := s.Get(ctx, "ns", "counter"); err != nil || string(value) != "1101" {
+		t.Errorf("counter = %s, %v, want 1101", value, err)
+	}
+}
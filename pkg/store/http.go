@@ -0,0 +1,195 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// maxRequestSize bounds the body of a request to Handler.
+const maxRequestSize = 64 << 20
+
+// httpRequest is the body of a request to Handler. Each operation uses the
+// fields of its arguments.
+type httpRequest struct {
+	Namespace string        `json:"ns"`
+	Key       string        `json:"key,omitempty"`
+	Value     []byte        `json:"value,omitempty"`
+	TTL       time.Duration `json:"ttl,omitempty"`
+	Delta     int64         `json:"delta,omitempty"`
+	Prefix    string        `json:"prefix,omitempty"`
+}
+
+// httpResponse is the body of a response from Handler.
+type httpResponse struct {
+	Value   []byte  `json:"value,omitempty"`
+	Found   bool    `json:"found,omitempty"`
+	Set     bool    `json:"set,omitempty"`
+	N       int64   `json:"n,omitempty"`
+	Entries []Entry `json:"entries,omitempty"`
+	Error   string  `json:"error,omitempty"`
+	// NotInteger is set when Error is an ErrNotInteger.
+	NotInteger bool `json:"notInteger,omitempty"`
+}
+
+// Handler serves s over HTTP to Remote stores, so the replicas and services
+// of a deployment can share one store. Each operation is a POST of JSON to
+// the path named after it, e.g. /get.
+func Handler(s Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		req := httpRequest{}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+			writeResponse(w, http.StatusBadRequest, httpResponse{Error: fmt.Sprintf("invalid request: %s", err)})
+			return
+		}
+		ctx := r.Context()
+		resp := httpResponse{}
+		var err error
+		switch op := path.Base(r.URL.Path); op {
+		case "get":
+			resp.Value, resp.Found, err = s.Get(ctx, req.Namespace, req.Key)
+		case "set":
+			err = s.Set(ctx, req.Namespace, req.Key, req.Value, req.TTL)
+		case "setifabsent":
+			resp.Set, err = s.SetIfAbsent(ctx, req.Namespace, req.Key, req.Value, req.TTL)
+		case "delete":
+			err = s.Delete(ctx, req.Namespace, req.Key)
+		case "incr":
+			resp.N, err = s.Incr(ctx, req.Namespace, req.Key, req.Delta)
+		case "scan":
+			resp.Entries, err = s.Scan(ctx, req.Namespace, req.Prefix)
+		default:
+			writeResponse(w, http.StatusNotFound, httpResponse{Error: fmt.Sprintf("unknown operation %q", op)})
+			return
+		}
+		if err != nil {
+			status := http.StatusInternalServerError
+			resp = httpResponse{Error: err.Error()}
+			if _, ok := err.(ErrNotInteger); ok {
+				status = http.StatusConflict
+				resp.NotInteger = true
+			}
+			writeResponse(w, status, resp)
+			return
+		}
+		writeResponse(w, http.StatusOK, resp)
+	})
+}
+
+func writeResponse(w http.ResponseWriter, status int, resp httpResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Remote is a Store served by Handler at URL.
+type Remote struct {
+	// URL is where Handler is served, e.g. http://store.default.svc/.
+	URL string
+	// Client sends the requests. Defaults to a client with a 10s timeout.
+	Client *http.Client
+
+	closed int32
+}
+
+var _ Store = (*Remote)(nil)
+
+var defaultClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewRemote returns the Store served by Handler at url.
+func NewRemote(url string) *Remote {
+	return &Remote{URL: url}
+}
+
+func (s *Remote) do(ctx context.Context, op string, req httpRequest) (httpResponse, error) {
+	if atomic.LoadInt32(&s.closed) != 0 {
+		return httpResponse{}, ErrClosed
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return httpResponse{}, err
+	}
+	hreq, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(s.URL, "/")+"/"+op, bytes.NewReader(body))
+	if err != nil {
+		return httpResponse{}, err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	client := s.Client
+	if client == nil {
+		client = defaultClient
+	}
+	hresp, err := client.Do(hreq.WithContext(ctx))
+	if err != nil {
+		return httpResponse{}, err
+	}
+	defer hresp.Body.Close()
+	resp := httpResponse{}
+	if err := json.NewDecoder(hresp.Body).Decode(&resp); err != nil {
+		return httpResponse{}, fmt.Errorf("store %s: invalid response with status %d: %s", op, hresp.StatusCode, err)
+	}
+	switch {
+	case resp.NotInteger:
+		return httpResponse{}, ErrNotInteger{Namespace: req.Namespace, Key: req.Key}
+	case hresp.StatusCode != http.StatusOK:
+		return httpResponse{}, fmt.Errorf("store %s: %s", op, resp.Error)
+	}
+	return resp, nil
+}
+
+func (s *Remote) Get(ctx context.Context, ns, key string) ([]byte, bool, error) {
+	resp, err := s.do(ctx, "get", httpRequest{Namespace: ns, Key: key})
+	if err != nil || !resp.Found {
+		return nil, false, err
+	}
+	if resp.Value == nil {
+		resp.Value = []byte{}
+	}
+	return resp.Value, true, nil
+}
+
+func (s *Remote) Set(ctx context.Context, ns, key string, value []byte, ttl time.Duration) error {
+	_, err := s.do(ctx, "set", httpRequest{Namespace: ns, Key: key, Value: value, TTL: ttl})
+	return err
+}
+
+func (s *Remote) SetIfAbsent(ctx context.Context, ns, key string, value []byte, ttl time.Duration) (bool, error) {
+	resp, err := s.do(ctx, "setifabsent", httpRequest{Namespace: ns, Key: key, Value: value, TTL: ttl})
+	return resp.Set, err
+}
+
+func (s *Remote) Delete(ctx context.Context, ns, key string) error {
+	_, err := s.do(ctx, "delete", httpRequest{Namespace: ns, Key: key})
+	return err
+}
+
+func (s *Remote) Incr(ctx context.Context, ns, key string, delta int64) (int64, error) {
+	resp, err := s.do(ctx, "incr", httpRequest{Namespace: ns, Key: key, Delta: delta})
+	return resp.N, err
+}
+
+func (s *Remote) Scan(ctx context.Context, ns, prefix string) ([]Entry, error) {
+	resp, err := s.do(ctx, "scan", httpRequest{Namespace: ns, Prefix: prefix})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Entries == nil {
+		resp.Entries = []Entry{}
+	}
+	return resp.Entries, nil
+}
+
+// Close stops s from being used, the served store stays open.
+func (s *Remote) Close() error {
+	atomic.StoreInt32(&s.closed, 1)
+	return nil
+}
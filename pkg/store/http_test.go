@@ -0,0 +1,59 @@
+package store_test
+
+import (
+	"context"
+	"github.com/botless/commands/pkg/store"
+	"github.com/botless/commands/pkg/store/storetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRemoteConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		server := httptest.NewServer(store.Handler(store.NewMemory()))
+		return &serving{Remote: store.NewRemote(server.URL + "/store/"), server: server}
+	})
+}
+
+// serving stops the server of a Remote store when it is closed.
+type serving struct {
+	*store.Remote
+	server *httptest.Server
+}
+
+func (s *serving) Close() error {
+	defer s.server.Close()
+	return s.Remote.Close()
+}
+
+func TestRemoteErrors(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(store.Handler(store.NewMemory()))
+	defer server.Close()
+	s := store.NewRemote(server.URL)
+
+	if err := s.Set(ctx, "ns", "key", []byte("text"), 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	_, err := s.Incr(ctx, "ns", "key", 1)
+	if want := (store.ErrNotInteger{Namespace: "ns", Key: "key"}); err != want {
+		t.Errorf("Incr of text = %v, want %v", err, want)
+	}
+
+	resp, err := http.Get(server.URL + "/get")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, _, err := s.Get(ctx, "ns", "key"); err != store.ErrClosed {
+		t.Errorf("Get after Close = %v, want ErrClosed", err)
+	}
+}
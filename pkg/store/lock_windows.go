@@ -0,0 +1,12 @@
+package store
+
+import (
+	"errors"
+	"os"
+)
+
+// lockFile is not implemented on windows, so File stores can not be opened
+// there.
+func lockFile(path string) (*os.File, error) {
+	return nil, errors.New("file stores are not supported on windows")
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Memory is a Store held in memory, lost when the process exits. It is meant
+// for tests and single replica deployments that can afford to lose state.
+type Memory struct {
+	t *table
+}
+
+var _ Store = (*Memory)(nil)
+
+// NewMemory returns an empty Memory store.
+func NewMemory() *Memory {
+	return &Memory{t: newTable()}
+}
+
+func (m *Memory) Get(ctx context.Context, ns, key string) ([]byte, bool, error) {
+	m.t.mu.Lock()
+	defer m.t.mu.Unlock()
+	e, ok := m.t.get(ns, key)
+	if !ok {
+		return nil, false, nil
+	}
+	return copyEntry(e).Value, true, nil
+}
+
+func (m *Memory) Set(ctx context.Context, ns, key string, value []byte, ttl time.Duration) error {
+	m.t.mu.Lock()
+	defer m.t.mu.Unlock()
+	m.t.put(ns, copyEntry(Entry{Key: key, Value: value, Expires: m.t.expiry(ttl)}))
+	return nil
+}
+
+func (m *Memory) SetIfAbsent(ctx context.Context, ns, key string, value []byte, ttl time.Duration) (bool, error) {
+	m.t.mu.Lock()
+	defer m.t.mu.Unlock()
+	if _, ok := m.t.get(ns, key); ok {
+		return false, nil
+	}
+	m.t.put(ns, copyEntry(Entry{Key: key, Value: value, Expires: m.t.expiry(ttl)}))
+	return true, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, ns, key string) error {
+	m.t.mu.Lock()
+	defer m.t.mu.Unlock()
+	m.t.remove(ns, key)
+	return nil
+}
+
+func (m *Memory) Incr(ctx context.Context, ns, key string, delta int64) (int64, error) {
+	m.t.mu.Lock()
+	defer m.t.mu.Unlock()
+	e, n, err := m.t.incr(ns, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	m.t.put(ns, e)
+	return n, nil
+}
+
+func (m *Memory) Scan(ctx context.Context, ns, prefix string) ([]Entry, error) {
+	m.t.mu.Lock()
+	defer m.t.mu.Unlock()
+	return m.t.scan(ns, prefix), nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,13 @@
+package store_test
+
+import (
+	"github.com/botless/commands/pkg/store"
+	"github.com/botless/commands/pkg/store/storetest"
+	"testing"
+)
+
+func TestMemoryConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return store.NewMemory()
+	})
+}
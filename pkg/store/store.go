@@ -0,0 +1,171 @@
+// Package store persists the state of stateful commands, such as karma,
+// reminders and polls, as namespaced keys and values.
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store holds values by namespace and key. Commands use their name as the
+// namespace so they do not collide.
+type Store interface {
+	// Get returns the value of key, and false when it is not set or expired.
+	Get(ctx context.Context, ns, key string) ([]byte, bool, error)
+	// Set sets key to value. A ttl of 0 or less keeps it until deleted.
+	Set(ctx context.Context, ns, key string, value []byte, ttl time.Duration) error
+	// SetIfAbsent atomically sets key to value unless it is already set and
+	// not expired, reporting whether it was set. Replicas use it to claim
+	// work so only one of them does it; the ttl lets the claim of a replica
+	// that dies be taken over.
+	SetIfAbsent(ctx context.Context, ns, key string, value []byte, ttl time.Duration) (bool, error)
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, ns, key string) error
+	// Incr atomically adds delta to the integer value of key and returns the
+	// result. A missing key counts as 0. The ttl of an existing key is kept,
+	// new keys do not expire.
+	Incr(ctx context.Context, ns, key string, delta int64) (int64, error)
+	// Scan returns the entries of ns whose key starts with prefix, sorted by
+	// key.
+	Scan(ctx context.Context, ns, prefix string) ([]Entry, error)
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// Entry is a key and its value.
+type Entry struct {
+	Key   string
+	Value []byte
+	// Expires is when the entry expires, zero if it does not.
+	Expires time.Time
+}
+
+// ErrNotInteger is returned by Incr when the value of the key is not an
+// integer.
+type ErrNotInteger struct {
+	Namespace, Key string
+}
+
+func (e ErrNotInteger) Error() string {
+	return fmt.Sprintf("value of %s/%s is not an integer", e.Namespace, e.Key)
+}
+
+// sweepEvery is the number of writes between sweeps of expired entries.
+const sweepEvery = 1000
+
+// table is the in memory state shared by the implementations. Callers hold
+// the lock.
+type table struct {
+	mu     sync.Mutex
+	items  map[string]map[string]Entry
+	writes int
+}
+
+func newTable() *table {
+	return &table{
+		items: make(map[string]map[string]Entry),
+	}
+}
+
+func (t *table) expired(e Entry, now time.Time) bool {
+	return !e.Expires.IsZero() && !now.Before(e.Expires)
+}
+
+func (t *table) get(ns, key string) (Entry, bool) {
+	e, ok := t.items[ns][key]
+	if !ok {
+		return Entry{}, false
+	}
+	if t.expired(e, time.Now()) {
+		delete(t.items[ns], key)
+		return Entry{}, false
+	}
+	return e, true
+}
+
+func (t *table) put(ns string, e Entry) {
+	if t.items[ns] == nil {
+		t.items[ns] = make(map[string]Entry)
+	}
+	t.items[ns][e.Key] = e
+	t.written()
+}
+
+func (t *table) remove(ns, key string) {
+	delete(t.items[ns], key)
+	if len(t.items[ns]) == 0 {
+		delete(t.items, ns)
+	}
+	t.written()
+}
+
+// incr computes the entry resulting from adding delta to key.
+func (t *table) incr(ns, key string, delta int64) (Entry, int64, error) {
+	e, ok := t.get(ns, key)
+	var n int64
+	if ok {
+		var err error
+		if n, err = strconv.ParseInt(string(e.Value), 10, 64); err != nil {
+			return Entry{}, 0, ErrNotInteger{Namespace: ns, Key: key}
+		}
+	} else {
+		e = Entry{Key: key}
+	}
+	n += delta
+	e.Value = []byte(strconv.FormatInt(n, 10))
+	return e, n, nil
+}
+
+func (t *table) scan(ns, prefix string) []Entry {
+	now := time.Now()
+	entries := []Entry{}
+	for key, e := range t.items[ns] {
+		if !strings.HasPrefix(key, prefix) || t.expired(e, now) {
+			continue
+		}
+		entries = append(entries, copyEntry(e))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries
+}
+
+// written counts a write, sweeping expired entries every sweepEvery writes.
+func (t *table) written() {
+	t.writes++
+	if t.writes%sweepEvery == 0 {
+		t.sweep()
+	}
+}
+
+func (t *table) sweep() {
+	now := time.Now()
+	for ns, entries := range t.items {
+		for key, e := range entries {
+			if t.expired(e, now) {
+				delete(entries, key)
+			}
+		}
+		if len(entries) == 0 {
+			delete(t.items, ns)
+		}
+	}
+}
+
+func (t *table) expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func copyEntry(e Entry) Entry {
+	e.Value = append([]byte(nil), e.Value...)
+	return e
+}
@@ -0,0 +1,343 @@
+// Package storetest is the conformance suite every store.Store
+// implementation must pass. Implementations call Run from their tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) store.Store {
+//			return store.NewMemory()
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"github.com/botless/commands/pkg/store"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ttl is short enough to wait for in tests, long enough to not expire
+// between a write and the read that follows it.
+const ttl = 200 * time.Millisecond
+
+// Run runs the conformance suite against stores returned by open. Each test
+// gets a new, empty store and closes it when done.
+func Run(t *testing.T, open func(t *testing.T) store.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.Store)
+	}{
+		{"GetMissing", testGetMissing},
+		{"SetGet", testSetGet},
+		{"Overwrite", testOverwrite},
+		{"Namespaces", testNamespaces},
+		{"SetIfAbsent", testSetIfAbsent},
+		{"SetIfAbsentExpired", testSetIfAbsentExpired},
+		{"SetIfAbsentConcurrent", testSetIfAbsentConcurrent},
+		{"Delete", testDelete},
+		{"TTL", testTTL},
+		{"Incr", testIncr},
+		{"IncrNotInteger", testIncrNotInteger},
+		{"IncrKeepsTTL", testIncrKeepsTTL},
+		{"IncrConcurrent", testIncrConcurrent},
+		{"Scan", testScan},
+		{"ValuesAreCopied", testValuesAreCopied},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := open(t)
+			defer func() {
+				if err := s.Close(); err != nil {
+					t.Errorf("Close: %v", err)
+				}
+			}()
+			tt.fn(t, s)
+		})
+	}
+}
+
+var ctx = context.Background()
+
+func mustSet(t *testing.T, s store.Store, ns, key, value string, ttl time.Duration) {
+	t.Helper()
+	if err := s.Set(ctx, ns, key, []byte(value), ttl); err != nil {
+		t.Fatalf("Set(%q, %q): %v", ns, key, err)
+	}
+}
+
+func expectValue(t *testing.T, s store.Store, ns, key, want string) {
+	t.Helper()
+	got, ok, err := s.Get(ctx, ns, key)
+	if err != nil {
+		t.Fatalf("Get(%q, %q): %v", ns, key, err)
+	}
+	if !ok {
+		t.Fatalf("Get(%q, %q): not found, want %q", ns, key, want)
+	}
+	if string(got) != want {
+		t.Fatalf("Get(%q, %q) = %q, want %q", ns, key, got, want)
+	}
+}
+
+func expectMissing(t *testing.T, s store.Store, ns, key string) {
+	t.Helper()
+	got, ok, err := s.Get(ctx, ns, key)
+	if err != nil {
+		t.Fatalf("Get(%q, %q): %v", ns, key, err)
+	}
+	if ok {
+		t.Fatalf("Get(%q, %q) = %q, want not found", ns, key, got)
+	}
+}
+
+func expectKeys(t *testing.T, s store.Store, ns, prefix string, want ...string) {
+	t.Helper()
+	entries, err := s.Scan(ctx, ns, prefix)
+	if err != nil {
+		t.Fatalf("Scan(%q, %q): %v", ns, prefix, err)
+	}
+	got := make([]string, len(entries))
+	for i, e := range entries {
+		got[i] = e.Key
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Scan(%q, %q) keys = %q, want %q", ns, prefix, got, want)
+	}
+}
+
+func testGetMissing(t *testing.T, s store.Store) {
+	expectMissing(t, s, "ns", "missing")
+}
+
+func testSetGet(t *testing.T, s store.Store) {
+	mustSet(t, s, "ns", "key", "value", 0)
+	expectValue(t, s, "ns", "key", "value")
+	mustSet(t, s, "ns", "empty", "", 0)
+	expectValue(t, s, "ns", "empty", "")
+}
+
+func testOverwrite(t *testing.T, s store.Store) {
+	mustSet(t, s, "ns", "key", "one", 0)
+	mustSet(t, s, "ns", "key", "two", 0)
+	expectValue(t, s, "ns", "key", "two")
+}
+
+func testNamespaces(t *testing.T, s store.Store) {
+	mustSet(t, s, "a", "key", "in a", 0)
+	mustSet(t, s, "b", "key", "in b", 0)
+	expectValue(t, s, "a", "key", "in a")
+	expectValue(t, s, "b", "key", "in b")
+	expectKeys(t, s, "a", "", "key")
+	if err := s.Delete(ctx, "a", "key"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	expectValue(t, s, "b", "key", "in b")
+}
+
+func setIfAbsent(t *testing.T, s store.Store, ns, key, value string, ttl time.Duration) bool {
+	t.Helper()
+	set, err := s.SetIfAbsent(ctx, ns, key, []byte(value), ttl)
+	if err != nil {
+		t.Fatalf("SetIfAbsent(%q, %q): %v", ns, key, err)
+	}
+	return set
+}
+
+func testSetIfAbsent(t *testing.T, s store.Store) {
+	if !setIfAbsent(t, s, "ns", "key", "first", 0) {
+		t.Fatalf("SetIfAbsent of a missing key = false, want true")
+	}
+	if setIfAbsent(t, s, "ns", "key", "second", 0) {
+		t.Fatalf("SetIfAbsent of a set key = true, want false")
+	}
+	expectValue(t, s, "ns", "key", "first")
+	if !setIfAbsent(t, s, "other", "key", "other", 0) {
+		t.Fatalf("SetIfAbsent in another namespace = false, want true")
+	}
+	if err := s.Delete(ctx, "ns", "key"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if !setIfAbsent(t, s, "ns", "key", "third", 0) {
+		t.Fatalf("SetIfAbsent of a deleted key = false, want true")
+	}
+	expectValue(t, s, "ns", "key", "third")
+}
+
+func testSetIfAbsentExpired(t *testing.T, s store.Store) {
+	if !setIfAbsent(t, s, "ns", "claim", "first", ttl) {
+		t.Fatalf("SetIfAbsent of a missing key = false, want true")
+	}
+	if setIfAbsent(t, s, "ns", "claim", "second", ttl) {
+		t.Fatalf("SetIfAbsent of an unexpired key = true, want false")
+	}
+	time.Sleep(2 * ttl)
+	if !setIfAbsent(t, s, "ns", "claim", "third", 0) {
+		t.Fatalf("SetIfAbsent of an expired key = false, want true")
+	}
+	expectValue(t, s, "ns", "claim", "third")
+	entries, err := s.Scan(ctx, "ns", "claim")
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(entries) != 1 || !entries[0].Expires.IsZero() {
+		t.Fatalf("Scan = %+v, want one entry without an expiry", entries)
+	}
+}
+
+func testSetIfAbsentConcurrent(t *testing.T, s store.Store) {
+	const workers = 16
+	wg := sync.WaitGroup{}
+	won := make(chan int, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			set, err := s.SetIfAbsent(ctx, "ns", "claim", []byte(fmt.Sprint(i)), 0)
+			if err != nil {
+				t.Errorf("SetIfAbsent: %v", err)
+				return
+			}
+			if set {
+				won <- i
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(won)
+	var winners []int
+	for i := range won {
+		winners = append(winners, i)
+	}
+	if len(winners) != 1 {
+		t.Fatalf("%d claims succeeded, want exactly 1", len(winners))
+	}
+	expectValue(t, s, "ns", "claim", fmt.Sprint(winners[0]))
+}
+
+func testDelete(t *testing.T, s store.Store) {
+	mustSet(t, s, "ns", "key", "value", 0)
+	if err := s.Delete(ctx, "ns", "key"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	expectMissing(t, s, "ns", "key")
+	if err := s.Delete(ctx, "ns", "key"); err != nil {
+		t.Fatalf("Delete of missing key: %v", err)
+	}
+}
+
+func testTTL(t *testing.T, s store.Store) {
+	mustSet(t, s, "ns", "short", "value", ttl)
+	mustSet(t, s, "ns", "forever", "value", 0)
+	expectValue(t, s, "ns", "short", "value")
+	entries, err := s.Scan(ctx, "ns", "short")
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Expires.IsZero() {
+		t.Fatalf("Scan = %+v, want one entry with an expiry", entries)
+	}
+
+	time.Sleep(2 * ttl)
+	expectMissing(t, s, "ns", "short")
+	expectValue(t, s, "ns", "forever", "value")
+	expectKeys(t, s, "ns", "", "forever")
+}
+
+func testIncr(t *testing.T, s store.Store) {
+	for _, step := range []struct {
+		delta, want int64
+	}{
+		{1, 1},
+		{1, 2},
+		{-5, -3},
+		{0, -3},
+	} {
+		got, err := s.Incr(ctx, "ns", "counter", step.delta)
+		if err != nil {
+			t.Fatalf("Incr(%d): %v", step.delta, err)
+		}
+		if got != step.want {
+			t.Fatalf("Incr(%d) = %d, want %d", step.delta, got, step.want)
+		}
+	}
+	expectValue(t, s, "ns", "counter", "-3")
+
+	mustSet(t, s, "ns", "set", "41", 0)
+	if got, err := s.Incr(ctx, "ns", "set", 1); err != nil || got != 42 {
+		t.Fatalf("Incr of set value = %d, %v, want 42", got, err)
+	}
+}
+
+func testIncrNotInteger(t *testing.T, s store.Store) {
+	mustSet(t, s, "ns", "key", "not a number", 0)
+	if got, err := s.Incr(ctx, "ns", "key", 1); err == nil {
+		t.Fatalf("Incr of non integer = %d, want error", got)
+	}
+	expectValue(t, s, "ns", "key", "not a number")
+}
+
+func testIncrKeepsTTL(t *testing.T, s store.Store) {
+	mustSet(t, s, "ns", "counter", "1", ttl)
+	if _, err := s.Incr(ctx, "ns", "counter", 1); err != nil {
+		t.Fatalf("Incr: %v", err)
+	}
+	time.Sleep(2 * ttl)
+	expectMissing(t, s, "ns", "counter")
+}
+
+func testIncrConcurrent(t *testing.T, s store.Store) {
+	const workers, each = 8, 50
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < each; j++ {
+				if _, err := s.Incr(ctx, "ns", "counter", 1); err != nil {
+					t.Errorf("Incr: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	expectValue(t, s, "ns", "counter", fmt.Sprint(workers*each))
+}
+
+func testScan(t *testing.T, s store.Store) {
+	expectKeys(t, s, "ns", "")
+	for _, key := range []string{"b/2", "a/1", "b/1", "c", "b/10"} {
+		mustSet(t, s, "ns", key, "value of "+key, 0)
+	}
+	mustSet(t, s, "other", "b/3", "value", 0)
+
+	expectKeys(t, s, "ns", "", "a/1", "b/1", "b/10", "b/2", "c")
+	expectKeys(t, s, "ns", "b/", "b/1", "b/10", "b/2")
+	expectKeys(t, s, "ns", "d")
+
+	entries, err := s.Scan(ctx, "ns", "c")
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(entries) != 1 || string(entries[0].Value) != "value of c" {
+		t.Fatalf("Scan(%q) = %+v, want the value of c", "c", entries)
+	}
+}
+
+func testValuesAreCopied(t *testing.T, s store.Store) {
+	value := []byte("value")
+	if err := s.Set(ctx, "ns", "key", value, 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	value[0] = 'X'
+	expectValue(t, s, "ns", "key", "value")
+
+	got, _, err := s.Get(ctx, "ns", "key")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got[0] = 'X'
+	expectValue(t, s, "ns", "key", "value")
+}
@@ -0,0 +1,238 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/botless/commands/pkg/logging"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WriterExporter writes each span as a line of JSON, e.g. to os.Stdout or a
+// buffer in tests.
+type WriterExporter struct {
+	mu sync.Mutex
+	W  io.Writer
+}
+
+var _ Exporter = (*WriterExporter)(nil)
+
+func (e *WriterExporter) Export(span *Span) {
+	b, err := json.Marshal(otlpSpanOf(span))
+	if err != nil {
+		logging.Default().Errorf("failed to marshal span %q: %s", span.Name, err)
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.W.Write(append(b, '\n'))
+}
+
+const (
+	defaultOTLPBatchSize     = 512
+	defaultOTLPFlushInterval = 5 * time.Second
+)
+
+// OTLPExporter sends spans in batches to an OpenTelemetry collector using
+// OTLP over HTTP with JSON encoding.
+type OTLPExporter struct {
+	endpoint string
+	service  string
+	client   *http.Client
+
+	spans chan *Span
+	flush chan chan struct{}
+	done  chan struct{}
+}
+
+var _ Exporter = (*OTLPExporter)(nil)
+
+// NewOTLPExporter starts an exporter posting to endpoint, e.g.
+// "http://localhost:4318/v1/traces".
+func NewOTLPExporter(endpoint, service string) *OTLPExporter {
+	e := &OTLPExporter{
+		endpoint: endpoint,
+		service:  service,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		spans:    make(chan *Span, defaultOTLPBatchSize*2),
+		flush:    make(chan chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// Export queues span, dropping it if the queue is full.
+func (e *OTLPExporter) Export(span *Span) {
+	select {
+	case e.spans <- span:
+	default:
+		logging.Default().Warnf("otlp exporter queue full, dropping span %q", span.Name)
+	}
+}
+
+// Shutdown sends the queued spans and stops the exporter.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case e.flush <- flushed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *OTLPExporter) run() {
+	ticker := time.NewTicker(defaultOTLPFlushInterval)
+	defer ticker.Stop()
+	var batch []*Span
+	for {
+		select {
+		case span := <-e.spans:
+			batch = append(batch, span)
+			if len(batch) >= defaultOTLPBatchSize {
+				e.send(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			e.send(batch)
+			batch = nil
+		case flushed := <-e.flush:
+			for len(e.spans) > 0 {
+				batch = append(batch, <-e.spans)
+			}
+			e.send(batch)
+			close(flushed)
+			return
+		}
+	}
+}
+
+func (e *OTLPExporter) send(batch []*Span) {
+	if len(batch) == 0 {
+		return
+	}
+	spans := make([]otlpSpan, len(batch))
+	for i, s := range batch {
+		spans[i] = otlpSpanOf(s)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []otlpAttribute{stringAttribute("service.name", e.service)},
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": "github.com/botless/commands"},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		logging.Default().Errorf("failed to marshal %d spans: %s", len(batch), err)
+		return
+	}
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logging.Default().Errorf("failed to export %d spans: %s", len(batch), err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		logging.Default().Errorf("failed to export %d spans: %s", len(batch), resp.Status)
+	}
+}
+
+// otlpSpan is the OTLP JSON encoding of a span.
+type otlpSpan struct {
+	TraceID      string          `json:"traceId"`
+	SpanID       string          `json:"spanId"`
+	ParentSpanID string          `json:"parentSpanId,omitempty"`
+	Name         string          `json:"name"`
+	Kind         int             `json:"kind"`
+	Start        string          `json:"startTimeUnixNano"`
+	End          string          `json:"endTimeUnixNano"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	Status       otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string            `json:"key"`
+	Value map[string]string `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func stringAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: map[string]string{"stringValue": value}}
+}
+
+func otlpSpanOf(s *Span) otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o := otlpSpan{
+		TraceID: s.SpanContext.TraceID.String(),
+		SpanID:  s.SpanContext.SpanID.String(),
+		Name:    s.Name,
+		// OTLP numbers kinds from 1, internal.
+		Kind:  int(s.Kind) + 1,
+		Start: strconv.FormatInt(s.Start.UnixNano(), 10),
+		End:   strconv.FormatInt(s.End.UnixNano(), 10),
+	}
+	if s.Parent.IsValid() {
+		o.ParentSpanID = s.Parent.String()
+	}
+	keys := make([]string, 0, len(s.Attributes)+1)
+	for k := range s.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if s.Service != "" {
+		o.Attributes = append(o.Attributes, stringAttribute("service.name", s.Service))
+	}
+	for _, k := range keys {
+		o.Attributes = append(o.Attributes, stringAttribute(k, s.Attributes[k]))
+	}
+	if s.Err != nil {
+		o.Status = otlpStatus{Code: 2, Message: s.Err.Error()}
+	} else {
+		o.Status = otlpStatus{Code: 1}
+	}
+	return o
+}
+
+// NewExporter returns the exporter named by kind: "stdout", "otlp" or "" for
+// none. endpoint is only used by "otlp".
+func NewExporter(kind, endpoint, service string, stdout io.Writer) (Exporter, error) {
+	switch kind {
+	case "":
+		return nil, nil
+	case "stdout":
+		return &WriterExporter{W: stdout}, nil
+	case "otlp":
+		if endpoint == "" {
+			return nil, fmt.Errorf("otlp exporter needs an endpoint")
+		}
+		return NewOTLPExporter(endpoint, service), nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q, expected \"stdout\" or \"otlp\"", kind)
+	}
+}
@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// TraceparentExtension is the CloudEvents extension carrying the W3C trace
+// context, see https://www.w3.org/TR/trace-context/#traceparent-header.
+const TraceparentExtension = "traceparent"
+
+type TraceID [16]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+type SpanID [8]byte
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext identifies a span within a trace.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent formats sc as a version 00 traceparent value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses a traceparent value.
+func ParseTraceparent(s string) (SpanContext, error) {
+	sc := SpanContext{}
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 {
+		return sc, fmt.Errorf("invalid traceparent %q", s)
+	}
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 || version[0] == 0xff {
+		return sc, fmt.Errorf("invalid traceparent version %q", parts[0])
+	}
+	if version[0] == 0 && len(parts) != 4 {
+		return sc, fmt.Errorf("invalid traceparent %q", s)
+	}
+	if err := decodeHex(parts[1], sc.TraceID[:]); err != nil {
+		return sc, fmt.Errorf("invalid trace id %q", parts[1])
+	}
+	if err := decodeHex(parts[2], sc.SpanID[:]); err != nil {
+		return sc, fmt.Errorf("invalid span id %q", parts[2])
+	}
+	flags := make([]byte, 1)
+	if err := decodeHex(parts[3], flags); err != nil {
+		return sc, fmt.Errorf("invalid trace flags %q", parts[3])
+	}
+	sc.Sampled = flags[0]&1 == 1
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("invalid traceparent %q, ids must not be zero", s)
+	}
+	return sc, nil
+}
+
+func decodeHex(s string, dst []byte) error {
+	if len(s) != hex.EncodedLen(len(dst)) {
+		return fmt.Errorf("expected %d hex characters", hex.EncodedLen(len(dst)))
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+func newTraceID() TraceID {
+	t := TraceID{}
+	rand.Read(t[:])
+	return t
+}
+
+func newSpanID() SpanID {
+	s := SpanID{}
+	rand.Read(s[:])
+	return s
+}
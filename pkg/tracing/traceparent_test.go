@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	tests := []struct {
+		in      string
+		sampled bool
+		err     bool
+	}{
+		{in: "00-" + traceID + "-" + spanID + "-01", sampled: true},
+		{in: "00-" + traceID + "-" + spanID + "-00"},
+		{in: " 00-" + traceID + "-" + spanID + "-03 ", sampled: true},
+		// Later versions may add fields.
+		{in: "01-" + traceID + "-" + spanID + "-01-extra", sampled: true},
+		{in: "00-" + traceID + "-" + spanID + "-01-extra", err: true},
+		{in: "ff-" + traceID + "-" + spanID + "-01", err: true},
+		{in: "0-" + traceID + "-" + spanID + "-01", err: true},
+		{in: "00-" + traceID[1:] + "-" + spanID + "-01", err: true},
+		{in: "00-" + traceID + "-" + spanID + "0-01", err: true},
+		{in: "00-" + traceID + "-" + spanID + "-1", err: true},
+		{in: "00-" + traceID + "-zz" + spanID[2:] + "-01", err: true},
+		{in: "00-00000000000000000000000000000000-" + spanID + "-01", err: true},
+		{in: "00-" + traceID + "-0000000000000000-01", err: true},
+		{in: "", err: true},
+		{in: "00-" + traceID, err: true},
+	}
+	for _, tt := range tests {
+		sc, err := ParseTraceparent(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseTraceparent(%q) = %+v, want an error", tt.in, sc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTraceparent(%q) unexpected error: %s", tt.in, err)
+			continue
+		}
+		if sc.TraceID.String() != traceID || sc.SpanID.String() != spanID || sc.Sampled != tt.sampled {
+			t.Errorf("ParseTraceparent(%q) = %s, want trace %s, span %s and sampled %t", tt.in, sc.Traceparent(), traceID, spanID, tt.sampled)
+		}
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	for _, sampled := range []bool{true, false} {
+		sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: sampled}
+		got, err := ParseTraceparent(sc.Traceparent())
+		if err != nil {
+			t.Fatalf("ParseTraceparent(%q): %s", sc.Traceparent(), err)
+		}
+		if got != sc {
+			t.Errorf("round trip of %+v = %+v", sc, got)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Package tracing records spans for botless commands and propagates the W3C
+// trace context through CloudEvent extensions.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = iota
+	SpanKindServer
+	SpanKindClient
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+// Exporter receives spans once they end.
+type Exporter interface {
+	Export(span *Span)
+}
+
+// Tracer starts spans and hands them to Exporter when they end. A nil
+// *Tracer starts no spans.
+type Tracer struct {
+	// Service is reported as the service.name of every span.
+	Service  string
+	Exporter Exporter
+}
+
+// Span is a timed operation within a trace. All methods are safe to call on
+// a nil *Span.
+type Span struct {
+	Name        string
+	Kind        SpanKind
+	Service     string
+	SpanContext SpanContext
+	Parent      SpanID
+	Start       time.Time
+	End         time.Time
+	Attributes  map[string]string
+	Err         error
+
+	mu       sync.Mutex
+	ended    bool
+	exporter Exporter
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of ctx holding span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span in ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Start begins a span that is a child of the span in ctx, or the root of a
+// new trace.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	parent := SpanContext{}
+	if p := SpanFromContext(ctx); p != nil {
+		parent = p.SpanContext
+	}
+	return t.start(ctx, name, kind, parent)
+}
+
+// StartRemote begins a span that continues the trace described by a
+// traceparent value received from another service. An empty or invalid
+// traceparent starts a new trace.
+func (t *Tracer) StartRemote(ctx context.Context, name string, kind SpanKind, traceparent string) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	parent, _ := ParseTraceparent(traceparent)
+	return t.start(ctx, name, kind, parent)
+}
+
+func (t *Tracer) start(ctx context.Context, name string, kind SpanKind, parent SpanContext) (context.Context, *Span) {
+	span := &Span{
+		Name:       name,
+		Kind:       kind,
+		Service:    t.Service,
+		Start:      time.Now(),
+		Attributes: make(map[string]string),
+		exporter:   t.Exporter,
+	}
+	if parent.IsValid() {
+		span.SpanContext = SpanContext{
+			TraceID: parent.TraceID,
+			SpanID:  newSpanID(),
+			Sampled: parent.Sampled,
+		}
+		span.Parent = parent.SpanID
+	} else {
+		span.SpanContext = SpanContext{
+			TraceID: newTraceID(),
+			SpanID:  newSpanID(),
+			Sampled: true,
+		}
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Attributes[key] = fmt.Sprint(value)
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Err = err
+	s.mu.Unlock()
+}
+
+// Traceparent returns the traceparent value identifying s, or "" for a nil
+// span.
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return s.SpanContext.Traceparent()
+}
+
+// Finish ends the span and exports it if sampled. Only the first call has
+// an effect.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	s.mu.Unlock()
+	if s.exporter != nil && s.SpanContext.Sampled {
+		s.exporter.Export(s)
+	}
+}